@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
+)
+
+// Default duration a check is silenced if not specified otherwise
+const defaultSilenceMinutes = 60
+
+// ChatopsResponse is the message returned to Slack or Mattermost.
+type ChatopsResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// Handle slash commands from Slack or Mattermost.
+// Usage: /checkbot failing | run <check> | silence <check> [minutes]
+func (app *application) chatops(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Slack and Mattermost both send the configured token with every command
+	if subtle.ConstantTimeCompare([]byte(r.PostForm.Get("token")), []byte(app.chatopsToken)) != 1 {
+		log.Warnf("Received chatops command with invalid token from %s", r.RemoteAddr)
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	log.Infof("Received chatops command '%s' from user %s", r.PostForm.Get("text"), r.PostForm.Get("user_name"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&ChatopsResponse{
+		ResponseType: "ephemeral",
+		Text:         app.runChatopsCommand(r.PostForm.Get("text")),
+	})
+}
+
+// Execute a chatops command and return the answer as text.
+func (app *application) runChatopsCommand(text string) string {
+	args := strings.Fields(text)
+	if len(args) == 0 {
+		return chatopsUsage()
+	}
+
+	switch args[0] {
+	case "failing":
+		failing := []string{}
+		for _, check := range app.scheduler.Checks() {
+			if check.Success == 0 {
+				failing = append(failing, check.Name+checkOwnership(check))
+			}
+		}
+		if len(failing) == 0 {
+			return "All checks are fine."
+		}
+		sort.Strings(failing)
+		return fmt.Sprintf("%d failing checks:\n%s", len(failing), strings.Join(failing, "\n"))
+	case "run":
+		if len(args) != 2 {
+			return chatopsUsage()
+		}
+		err := app.scheduler.Trigger(args[1])
+		if err != nil {
+			return err.Error()
+		}
+		return "Check " + args[1] + " will run now."
+	case "silence":
+		if len(args) < 2 || len(args) > 3 {
+			return chatopsUsage()
+		}
+		minutes := defaultSilenceMinutes
+		if len(args) == 3 {
+			var err error
+			minutes, err = strconv.Atoi(args[2])
+			if err != nil || minutes <= 0 {
+				return "Invalid number of minutes: " + args[2]
+			}
+		}
+		err := app.scheduler.Silence(args[1], time.Duration(minutes)*time.Minute)
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("Check %s is silenced for %d minutes.", args[1], minutes)
+	default:
+		return chatopsUsage()
+	}
+}
+
+// Return the owning team and runbook of a check as text.
+func checkOwnership(check *healthcheck.Check) string {
+	ownership := []string{}
+	if check.Team != "" {
+		ownership = append(ownership, "team: "+check.Team)
+	}
+	if check.Owner != "" {
+		ownership = append(ownership, "owner: "+check.Owner)
+	}
+	if check.RunbookURL != "" {
+		ownership = append(ownership, "runbook: "+check.RunbookURL)
+	}
+	if len(ownership) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(ownership, ", ") + ")"
+}
+
+// Return the usage of the chatops commands.
+func chatopsUsage() string {
+	return "Usage: failing | run <check> | silence <check> [minutes]"
+}
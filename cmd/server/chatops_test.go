@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
+)
+
+func TestRunChatopsCommand(t *testing.T) {
+
+	check, err := healthcheck.NewCheck("test_chatops", "placeholder", "Gauge", "placeholder", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check.Success = 0
+	check.Team = "platform"
+	check.RunbookURL = "https://runbooks.example.com/chatops"
+	scheduler := healthcheck.NewScheduler(healthcheck.Options{})
+	scheduler.AddCheck(check)
+	app := &application{
+		scheduler: scheduler,
+	}
+
+	if !strings.Contains(app.runChatopsCommand("failing"), "test_chatops (team: platform, runbook: https://runbooks.example.com/chatops)") {
+		t.Error("Expected failing check with ownership in answer")
+	}
+
+	app.runChatopsCommand("silence test_chatops 5")
+	if check.Silenced <= time.Now().Unix() {
+		t.Error("Expected check to be silenced")
+	}
+
+	app.runChatopsCommand("run test_chatops")
+	if check.Silenced != 0 {
+		t.Error("Expected check not to be silenced after run")
+	}
+
+	if app.runChatopsCommand("run unknown") != "Unknown check unknown" {
+		t.Error("Expected error for unknown check")
+	}
+
+	if app.runChatopsCommand("help") != chatopsUsage() {
+		t.Error("Expected usage for unknown command")
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
+)
+
+// Property of a check that is compared by the diff command
+type checkProperty struct {
+	name  string
+	value string
+}
+
+// Compare a proposed script base against a running instance or an old script base.
+// Returns the exit code: 0 if there are no changes, 1 if there are changes and 2 on errors.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flagScriptBase := flags.String("scriptBase", "scripts", "Base path for the proposed check scripts")
+	flagMetricsPrefix := flags.String("metricsPrefix", "checkbot", "Prefix for all metrics")
+	flagOld := flags.String("old", "", "Base path for the old check scripts")
+	flagURL := flags.String("url", "", "URL of the running instance, e.g. https://localhost:4444")
+	flagUser := flags.String("user", "admin", "User for the basic auth of the running instance")
+	flagPassword := flags.String("password", "admin", "Password for the basic auth of the running instance")
+	flagInsecure := flags.Bool("insecure", false, "Skip verification of the server certificate")
+	flagProxyURL := flags.String("proxyURL", "", "Proxy to connect to the running instance, e.g. http://proxy:3128")
+	flagNoProxy := flags.String("noProxy", "", "Comma separated list of hosts, domains or CIDRs to connect directly")
+	flags.Parse(args)
+
+	if (*flagOld == "") == (*flagURL == "") {
+		fmt.Fprintln(os.Stderr, "Either -old or -url is required")
+		return 2
+	}
+
+	proposed, err := healthcheck.LoadChecks(*flagScriptBase, *flagMetricsPrefix, healthcheck.ProxyConfig{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load proposed checks:\n%v\n", err)
+		return 2
+	}
+
+	var current map[string]*healthcheck.Check
+	if *flagOld != "" {
+		current, err = healthcheck.LoadChecks(*flagOld, *flagMetricsPrefix, healthcheck.ProxyConfig{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load old checks:\n%v\n", err)
+			return 2
+		}
+	} else {
+		current, err = fetchChecks(*flagURL, *flagUser, *flagPassword, *flagInsecure, healthcheck.ProxyConfig{URL: *flagProxyURL, NoProxy: *flagNoProxy})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to fetch checks: %v\n", err)
+			return 2
+		}
+	}
+
+	changes := diffChecks(current, proposed)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	fmt.Println("No changes.")
+	return 0
+}
+
+// Fetch the checks from the api of a running instance.
+func fetchChecks(url string, user string, password string, insecure bool, proxy healthcheck.ProxyConfig) (map[string]*healthcheck.Check, error) {
+	transport, err := proxy.Transport(&tls.Config{InsecureSkipVerify: insecure})
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(url, "/")+"/api/checks", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(user, password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("Unexpected response status " + resp.Status)
+	}
+
+	response := map[string]*CheckResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := map[string]*healthcheck.Check{}
+	for name, r := range response {
+		if r.Check == nil {
+			return nil, errors.New("Invalid check " + name)
+		}
+		r.Check.Proxy = r.Proxy
+		checks[name] = r.Check
+	}
+	return checks, nil
+}
+
+// Compare two lists of checks and return the added, removed and changed checks.
+func diffChecks(current map[string]*healthcheck.Check, proposed map[string]*healthcheck.Check) []string {
+	names := []string{}
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range proposed {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []string{}
+	for _, name := range names {
+		currentCheck, inCurrent := current[name]
+		proposedCheck, inProposed := proposed[name]
+
+		switch {
+		case !inCurrent:
+			changes = append(changes, "+ "+name)
+		case !inProposed:
+			changes = append(changes, "- "+name)
+		default:
+			currentProperties := checkProperties(currentCheck)
+			proposedProperties := checkProperties(proposedCheck)
+
+			changed := []string{}
+			for i := range currentProperties {
+				if currentProperties[i].value != proposedProperties[i].value {
+					changed = append(changed, fmt.Sprintf("    %s: %q -> %q",
+						currentProperties[i].name, currentProperties[i].value, proposedProperties[i].value))
+				}
+			}
+			if len(changed) > 0 {
+				changes = append(changes, "~ "+name)
+				changes = append(changes, changed...)
+			}
+		}
+	}
+
+	return changes
+}
+
+// Return the properties of a check that are relevant for the diff.
+func checkProperties(check *healthcheck.Check) []checkProperty {
+	return []checkProperty{
+		{"script", check.Hash},
+		{"active", strconv.FormatBool(check.Active)},
+		{"type", check.MetricType},
+		{"help", check.Help},
+		{"interval", strconv.Itoa(check.Interval)},
+		{"encoding", check.Encoding},
+		{"statusinfo", strconv.FormatBool(check.StatusInfo)},
+		{"raw", strconv.FormatBool(check.Raw)},
+		{"priority", check.Priority},
+		{"owner", check.Owner},
+		{"team", check.Team},
+		{"runbook", check.RunbookURL},
+		{"proxy", redactProxy(check.Proxy)},
+		{"drift", strconv.FormatBool(check.Drift)},
+		{"golden", check.Golden},
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
 )
 
 // Overview of all checks
@@ -14,11 +18,31 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.render(w, r, "checks.page.tmpl", &templateData{
-		Checklist:     app.checkList,
+		Checklist:     app.scheduler.Checks(),
 		Configuration: app.config,
 	})
 }
 
+// CheckResponse is a check listed by the api, the password of the proxy is redacted.
+type CheckResponse struct {
+	*healthcheck.Check
+	Proxy string
+}
+
+// List of all checks as json
+func (app *application) checks(w http.ResponseWriter, r *http.Request) {
+	response := map[string]*CheckResponse{}
+	for name, check := range app.scheduler.Checks() {
+		response[name] = &CheckResponse{Check: check, Proxy: redactProxy(check.Proxy)}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		app.serverError(w, err)
+	}
+}
+
 // Render sandbox form for debugging
 func (app *application) sandbox(w http.ResponseWriter, r *http.Request) {
 
@@ -34,7 +58,7 @@ func (app *application) sandbox(w http.ResponseWriter, r *http.Request) {
 
 		// Load existing script to sandbox
 		if r.PostForm.Get("load") != "none" {
-			check := app.checkList[r.PostForm.Get("load")]
+			check := app.scheduler.Checks()[r.PostForm.Get("load")]
 			r.PostForm.Set("sandbox", app.loadSandbox(*check))
 		}
 
@@ -43,7 +67,7 @@ func (app *application) sandbox(w http.ResponseWriter, r *http.Request) {
 	}
 
 	app.render(w, r, "sandbox.page.tmpl", &templateData{
-		Checklist:     app.checkList,
+		Checklist:     app.scheduler.Checks(),
 		Sandbox:       sandbox,
 		Configuration: app.config,
 	})
@@ -53,12 +77,47 @@ func (app *application) sandbox(w http.ResponseWriter, r *http.Request) {
 func (app *application) reload(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
 		log.Info("Reloading checks..")
-		// Stop all checks
-		app.stopChecks()
-		// Rebuild all checks
-		app.buildMetrics()
-		// Start all checks
-		app.startChecks()
+		// Stop, rebuild and start all checks
+		err := app.scheduler.Reload()
+		if err != nil {
+			log.Errorf("Failed to reload all checks: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+// Trigger an immediate run of a check
+func (app *application) run(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		err := app.scheduler.Trigger(r.URL.Query().Get("check"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		http.NotFound(w, r)
+	}
+}
+
+// Silence a check for a number of minutes
+func (app *application) silence(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		minutes := defaultSilenceMinutes
+		if r.URL.Query().Get("minutes") != "" {
+			var err error
+			minutes, err = strconv.Atoi(r.URL.Query().Get("minutes"))
+			if err != nil || minutes <= 0 {
+				app.clientError(w, http.StatusBadRequest)
+				return
+			}
+		}
+		err := app.scheduler.Silence(r.URL.Query().Get("check"), time.Duration(minutes)*time.Minute)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	} else {
 		http.NotFound(w, r)
 	}
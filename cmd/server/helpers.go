@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"net/url"
 	"runtime/debug"
-	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -47,11 +47,11 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, name stri
 	buf.WriteTo(w)
 }
 
-// MapToString will convert a map to a string.
-func MapToString(m map[string]string) string {
-	tmp := ""
-	for key, value := range m {
-		tmp += key + ":" + value + ","
+// Return the proxy with the password of the userinfo redacted.
+func redactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return "xxxxx" // Do not print an unparsable proxy, it might contain a password
 	}
-	return strings.TrimSuffix(tmp, ",")
+	return u.Redacted()
 }
@@ -3,24 +3,29 @@ package main
 import (
 	"flag"
 	"html/template"
+	"math"
 	"net/http"
+	"os"
+	"runtime"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
 )
 
 // Global data
 type application struct {
-	scriptBase       string
-	metricsPrefix    string
-	logLevel         string
-	managementPwd    string
-	enableSandbox    bool
-	checkList        map[string]*Check
-	lastrunMetric    *prometheus.GaugeVec
-	lastresultMetric *prometheus.GaugeVec
-	templateCache    map[string]*template.Template
-	config           Configuration
+	logLevel      string
+	managementPwd string
+	enableSandbox bool
+	chatopsToken  string
+	proxy         healthcheck.ProxyConfig
+	rawRegistry   *prometheus.Registry
+	scheduler     *healthcheck.Scheduler
+	notifiers     []Notifier
+	reporter      *Reporter
+	templateCache map[string]*template.Template
+	config        Configuration
 }
 
 func init() {
@@ -38,18 +43,36 @@ var Version = "unspecified"
 // Build is provided by ldflags
 var Build = "unspecified"
 
+// Prefix of the status metrics, independent of the metrics prefix of the checks
+const statusPrefix = "checkbot"
+
 func main() {
+	// Compare check scripts
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiff(os.Args[2:]))
+	}
+
 	// Parse command line paramters
 	flagScriptBase := flag.String("scriptBase", "scripts", "Base path for the check scripts")
 	flagMetricsPrefix := flag.String("metricsPrefix", "checkbot", "Prefix for all metrics")
 	flagLogLevel := flag.String("logLevel", "info", "Log level for application (error|warn|info|debug|trace")
 	flagManagementPwd := flag.String("managementPwd", "admin", "Password for managing endpoints")
 	flagEnableSandbox := flag.Bool("enableSandbox", false, "Enable debugging sandbox")
+	flagMaxConcurrentChecks := flag.Int("maxConcurrentChecks", 0, "Maximum number of concurrent checks, derived from the cpu limit if 0")
+	flagLoadSheddingThreshold := flag.Float64("loadSheddingThreshold", 0.9, "Ratio of the cpu limit to start shedding load, disabled if 0")
+	flagLogFailureEvery := flag.Int("logFailureEvery", 10, "Log the first and every nth failure in a row of a check")
+	flagChatopsToken := flag.String("chatopsToken", "", "Token for Slack or Mattermost slash commands, chatops is disabled if empty")
+	flagProxyURL := flag.String("proxyURL", "", "Proxy for outbound connections, e.g. http://proxy:3128 or socks5://proxy:1080")
+	flagNoProxy := flag.String("noProxy", "", "Comma separated list of hosts, domains or CIDRs to connect directly")
+	flagWebhookURL := flag.String("webhookURL", "", "URL of a webhook to send notifications to")
+	flagSMTPAddr := flag.String("smtpAddr", "", "Address of a smtp server to send notifications to, e.g. smtp.example.com:25")
+	flagSMTPFrom := flag.String("smtpFrom", "checkbot@localhost", "Sender of notification emails")
+	flagSMTPTo := flag.String("smtpTo", "", "Comma separated list of recipients of notification emails")
+	flagSMTPUser := flag.String("smtpUser", "", "User for the smtp server")
+	flagSMTPPassword := flag.String("smtpPassword", "", "Password for the smtp server")
+	flagReportSchedule := flag.String("reportSchedule", "", "Send a report of all checks (daily|weekly), disabled if empty")
 	flag.Parse()
 
-	// Create map for all checks
-	checkList := map[string]*Check{}
-
 	// Initialize a new template cache
 	templateCache, err := newTemplateCache("./ui/html/")
 	if err != nil {
@@ -65,16 +88,13 @@ func main() {
 
 	// Global application variables
 	app := &application{
-		scriptBase:       *flagScriptBase,
-		metricsPrefix:    *flagMetricsPrefix,
-		logLevel:         *flagLogLevel,
-		managementPwd:    *flagManagementPwd,
-		enableSandbox:    *flagEnableSandbox,
-		checkList:        checkList,
-		lastrunMetric:    nil,
-		lastresultMetric: nil,
-		templateCache:    templateCache,
-		config:           *config,
+		logLevel:      *flagLogLevel,
+		managementPwd: *flagManagementPwd,
+		enableSandbox: *flagEnableSandbox,
+		chatopsToken:  *flagChatopsToken,
+		proxy:         healthcheck.ProxyConfig{URL: *flagProxyURL, NoProxy: *flagNoProxy},
+		templateCache: templateCache,
+		config:        *config,
 	}
 
 	// parse custom loglevel
@@ -93,11 +113,73 @@ func main() {
 	// Show build information
 	log.Infof("Version: %s, Build: %s", Version, Build)
 
+	// Tune GOMAXPROCS to the cpu limit unless set explicitly
+	cpuLimit, err := healthcheck.DetectCPULimit()
+	if err == nil && cpuLimit > 0 {
+		if os.Getenv("GOMAXPROCS") == "" {
+			runtime.GOMAXPROCS(int(math.Ceil(cpuLimit)))
+		}
+		log.Infof("Detected cpu limit of %.2f, using GOMAXPROCS %d", cpuLimit, runtime.GOMAXPROCS(0))
+	}
+
+	// Limit concurrent checks based on the cpu limit
+	loadShedder, err := healthcheck.NewLoadShedder(*flagMaxConcurrentChecks, *flagLoadSheddingThreshold, statusPrefix, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	loadShedder.Start()
+
+	// Create the scheduler, results are recorded for the report
+	app.rawRegistry = prometheus.NewRegistry()
+	app.scheduler = healthcheck.NewScheduler(healthcheck.Options{
+		ScriptBase:      *flagScriptBase,
+		MetricsPrefix:   *flagMetricsPrefix,
+		StatusPrefix:    statusPrefix,
+		Proxy:           app.proxy,
+		LogFailureEvery: *flagLogFailureEvery,
+		LoadShedder:     loadShedder,
+		RawRegisterer:   app.rawRegistry,
+		OnResult: func(check *healthcheck.Check) {
+			app.reporter.record(check)
+		},
+	})
+
 	// Build metrics and fill checklist
-	app.buildMetrics()
+	err = app.scheduler.Load()
+	if err != nil {
+		log.Errorf("Failed to load all checks: %v", err)
+	}
+
+	// Setup the notifiers
+	if *flagWebhookURL != "" {
+		notifier, err := newWebhookNotifier(*flagWebhookURL, app.proxy)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.notifiers = append(app.notifiers, notifier)
+	}
+	if *flagSMTPAddr != "" {
+		notifier, err := newEmailNotifier(*flagSMTPAddr, *flagSMTPFrom, *flagSMTPTo, *flagSMTPUser, *flagSMTPPassword)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.notifiers = append(app.notifiers, notifier)
+	}
+
+	// Send scheduled reports
+	if *flagReportSchedule != "" {
+		if len(app.notifiers) == 0 {
+			log.Fatal("Scheduled reports need a notifier, set -webhookURL or -smtpAddr")
+		}
+		app.reporter = newReporter(app.scheduler.Checks(), app.notifiers)
+		err = app.reporter.start(*flagReportSchedule)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Start running the checks
-	app.startChecks()
+	app.scheduler.Start()
 
 	// Start the server
 	log.Infof("Starting server on :4444")
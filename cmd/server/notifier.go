@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
+)
+
+// Notifier sends messages to an external system.
+type Notifier interface {
+	Notify(subject string, body string) error
+}
+
+// WebhookNotifier posts messages as json to a webhook, e.g. Slack or Mattermost.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// EmailNotifier sends messages as email using a smtp server.
+type EmailNotifier struct {
+	addr     string
+	from     string
+	to       []string
+	user     string
+	password string
+}
+
+// WebhookMessage is the payload posted to a webhook.
+type WebhookMessage struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+}
+
+// Create a notifier for a webhook using the proxy.
+func newWebhookNotifier(url string, proxy healthcheck.ProxyConfig) (*WebhookNotifier, error) {
+	transport, err := proxy.Transport(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}, nil
+}
+
+// Notify posts the message to the webhook.
+func (n *WebhookNotifier) Notify(subject string, body string) error {
+	data, err := json.Marshal(&WebhookMessage{
+		Subject: subject,
+		Text:    subject + "\n\n" + body,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return errors.New("Webhook responded with status " + resp.Status)
+	}
+	return nil
+}
+
+// Create a notifier for email, to is a comma separated list of recipients.
+func newEmailNotifier(addr string, from string, to string, user string, password string) (*EmailNotifier, error) {
+	recipients := []string{}
+	for _, recipient := range strings.Split(to, ",") {
+		if strings.TrimSpace(recipient) != "" {
+			recipients = append(recipients, strings.TrimSpace(recipient))
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("No recipients for email notifications")
+	}
+
+	return &EmailNotifier{
+		addr:     addr,
+		from:     from,
+		to:       recipients,
+		user:     user,
+		password: password,
+	}, nil
+}
+
+// Notify sends the message as email.
+func (n *EmailNotifier) Notify(subject string, body string) error {
+	var auth smtp.Auth
+	if n.user != "" {
+		host := strings.Split(n.addr, ":")[0]
+		auth = smtp.PlainAuth("", n.user, n.password, host)
+	}
+
+	msg := "From: " + n.from + "\r\n" +
+		"To: " + strings.Join(n.to, ",") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(body, "\n", "\r\n")
+
+	return smtp.SendMail(n.addr, auth, n.from, n.to, []byte(msg))
+}
+
+// Send a message to all notifiers.
+func notifyAll(notifiers []Notifier, subject string, body string) {
+	for _, notifier := range notifiers {
+		err := notifier.Notify(subject, body)
+		if err != nil {
+			log.Warnf("Failed to send notification '%s': %v", subject, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testpairRecipients struct {
+	to         string
+	recipients []string
+	hasError   bool
+}
+
+var testsRecipients = []testpairRecipients{
+	{"", nil, true},
+	{" , ", nil, true},
+	{"ops@example.com", []string{"ops@example.com"}, false},
+	{"ops@example.com, dev@example.com,", []string{"ops@example.com", "dev@example.com"}, false},
+}
+
+func TestNewEmailNotifier(t *testing.T) {
+	for _, pair := range testsRecipients {
+		notifier, err := newEmailNotifier("localhost:25", "checkbot@localhost", pair.to, "", "")
+
+		if (err != nil) != pair.hasError {
+			t.Errorf("Unexpected error for %q: %v", pair.to, err)
+		}
+		if err == nil && !reflect.DeepEqual(notifier.to, pair.recipients) {
+			t.Errorf("Expected recipients %v but found %v", pair.recipients, notifier.to)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
+)
+
+// Number of checks listed as top failures in a report
+const reportTopFailures = 5
+
+// Reporter compiles a summary of all check runs and sends it to the notifiers.
+type Reporter struct {
+	mutex     sync.Mutex
+	stats     map[string]*reportStats // Statistics of the current period
+	known     map[string]bool         // Checks known before the current period
+	since     time.Time
+	notifiers []Notifier
+}
+
+// Statistics of a check for the report.
+type reportStats struct {
+	name      string
+	ownership string
+	runs      int
+	failures  int
+}
+
+// Create a new reporter, the given checks are not reported as new checks.
+func newReporter(checkList map[string]*healthcheck.Check, notifiers []Notifier) *Reporter {
+	known := map[string]bool{}
+	for name := range checkList {
+		known[name] = true
+	}
+
+	return &Reporter{
+		stats:     map[string]*reportStats{},
+		known:     known,
+		since:     time.Now(),
+		notifiers: notifiers,
+	}
+}
+
+// Record the result of a check run.
+func (r *Reporter) record(check *healthcheck.Check) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats, ok := r.stats[check.Name]
+	if !ok {
+		stats = &reportStats{name: check.Name}
+		r.stats[check.Name] = stats
+	}
+	stats.ownership = checkOwnership(check)
+	stats.runs++
+	if check.Success != 1 {
+		stats.failures++
+	}
+}
+
+// Send reports in the background, schedule is either daily or weekly.
+func (r *Reporter) start(schedule string) error {
+	if schedule != "daily" && schedule != "weekly" {
+		return errors.New("Unknown report schedule " + schedule)
+	}
+
+	go func() {
+		for {
+			next := nextReport(time.Now(), schedule)
+			log.Infof("Schedule next %s report for %s", schedule, next)
+			time.Sleep(time.Until(next))
+
+			subject, body := r.compile(time.Now())
+			notifyAll(r.notifiers, subject, body)
+		}
+	}()
+	return nil
+}
+
+// Compile the report of the current period and start a new period.
+func (r *Reporter) compile(now time.Time) (string, string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := []*reportStats{}
+	for _, s := range r.stats {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].name < stats[j].name })
+
+	subject := fmt.Sprintf("Checkbot report %s - %s", humanDate(r.since.Unix()), humanDate(now.Unix()))
+
+	var body strings.Builder
+	body.WriteString("Availability:\n")
+	if len(stats) == 0 {
+		body.WriteString("  no checks have run\n")
+	}
+	for _, s := range stats {
+		fmt.Fprintf(&body, "  %s %.1f%% (%d/%d)\n", s.name, 100*float64(s.runs-s.failures)/float64(s.runs), s.runs-s.failures, s.runs)
+	}
+
+	// Checks with most failures first
+	failing := []*reportStats{}
+	for _, s := range stats {
+		if s.failures > 0 {
+			failing = append(failing, s)
+		}
+	}
+	sort.SliceStable(failing, func(i, j int) bool { return failing[i].failures > failing[j].failures })
+	if len(failing) > reportTopFailures {
+		failing = failing[:reportTopFailures]
+	}
+
+	body.WriteString("\nTop failures:\n")
+	if len(failing) == 0 {
+		body.WriteString("  none\n")
+	}
+	for _, s := range failing {
+		fmt.Fprintf(&body, "  %s %d failures%s\n", s.name, s.failures, s.ownership)
+	}
+
+	body.WriteString("\nNew checks:\n")
+	added := 0
+	for _, s := range stats {
+		if !r.known[s.name] {
+			fmt.Fprintf(&body, "  %s\n", s.name)
+			r.known[s.name] = true
+			added++
+		}
+	}
+	if added == 0 {
+		body.WriteString("  none\n")
+	}
+
+	// Start a new period
+	r.stats = map[string]*reportStats{}
+	r.since = now
+
+	return subject, body.String()
+}
+
+// Return the time of the next report, daily at midnight or weekly on monday at midnight.
+func nextReport(now time.Time, schedule string) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if schedule == "weekly" {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
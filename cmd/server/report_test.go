@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
+)
+
+func TestCompileReport(t *testing.T) {
+
+	known, err := healthcheck.NewCheck("test_known", "placeholder", "Gauge", "placeholder", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := healthcheck.NewCheck("test_added", "placeholder", "Gauge", "placeholder", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added.Team = "platform"
+
+	reporter := newReporter(map[string]*healthcheck.Check{known.Name: known}, nil)
+
+	known.Success = 1
+	reporter.record(known)
+	reporter.record(known)
+	added.Success = 0
+	reporter.record(added)
+	added.Success = 1
+	reporter.record(added)
+
+	_, body := reporter.compile(time.Now())
+
+	expected := []string{
+		"  test_added 50.0% (1/2)",
+		"  test_known 100.0% (2/2)",
+		"  test_added 1 failures (team: platform)",
+		"New checks:\n  test_added\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(body, e) {
+			t.Errorf("Expected report to contain %q but found %s", e, body)
+		}
+	}
+
+	// A new period does not contain any runs or new checks
+	_, body = reporter.compile(time.Now())
+	if !strings.Contains(body, "no checks have run") || !strings.Contains(body, "New checks:\n  none\n") {
+		t.Errorf("Expected empty report but found %s", body)
+	}
+}
+
+type testpairNextReport struct {
+	now      time.Time
+	schedule string
+	next     time.Time
+}
+
+var testsNextReport = []testpairNextReport{
+	// 2026-10-15 is a thursday
+	{time.Date(2026, 10, 15, 13, 30, 0, 0, time.UTC), "daily", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+	{time.Date(2026, 10, 15, 13, 30, 0, 0, time.UTC), "weekly", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+	{time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), "weekly", time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)},
+}
+
+func TestNextReport(t *testing.T) {
+	for _, pair := range testsNextReport {
+		next := nextReport(pair.now, pair.schedule)
+
+		if !next.Equal(pair.next) {
+			t.Errorf("Expected next report at %s but found %s", pair.next, next)
+		}
+	}
+}
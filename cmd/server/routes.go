@@ -16,6 +16,12 @@ func (app *application) routes() http.Handler {
 	// Metrics endpoint for Prometheus
 	mux.Handle("/metrics", promhttp.Handler())
 
+	// Metrics endpoint for checks with high cardinality
+	mux.Handle("/metrics/raw", promhttp.HandlerFor(app.rawRegistry, promhttp.HandlerOpts{}))
+
+	// Api endpoint listing all checks
+	mux.Handle("/api/checks", httpauth.SimpleBasicAuth("admin", app.managementPwd)(http.HandlerFunc(app.checks)))
+
 	// Sandbox
 	if app.config.Sandbox {
 		mux.Handle("/sandbox", httpauth.SimpleBasicAuth("admin", app.managementPwd)(http.HandlerFunc(app.sandbox)))
@@ -27,6 +33,15 @@ func (app *application) routes() http.Handler {
 	// Reload scripts endpoint
 	mux.Handle("/reload", httpauth.SimpleBasicAuth("admin", app.managementPwd)(http.HandlerFunc(app.reload)))
 
+	// Run and silence checks endpoints
+	mux.Handle("/run", httpauth.SimpleBasicAuth("admin", app.managementPwd)(http.HandlerFunc(app.run)))
+	mux.Handle("/silence", httpauth.SimpleBasicAuth("admin", app.managementPwd)(http.HandlerFunc(app.silence)))
+
+	// Chatops endpoint for Slack or Mattermost slash commands
+	if app.chatopsToken != "" {
+		mux.HandleFunc("/chatops", app.chatops)
+	}
+
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	mux.Handle("/static/", http.StripPrefix("/static", fileServer))
 
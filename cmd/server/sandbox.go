@@ -7,6 +7,7 @@ import (
 	"regexp"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
 )
 
 // Sandbox can test check scripts
@@ -17,7 +18,7 @@ type Sandbox struct {
 }
 
 // Load an existing script from file and return as string.
-func (app *application) loadSandbox(check Check) string {
+func (app *application) loadSandbox(check healthcheck.Check) string {
 	data, err := os.ReadFile(check.File)
 	if err != nil {
 		log.Warnf("Failed to load script to sandbox: %v", err)
@@ -51,6 +52,9 @@ func (app *application) runSandbox(script string) *Sandbox {
 
 	// Execute sandbox script
 	cmd := exec.Command(os.TempDir()+"/sandbox.sh")
+	if proxyEnv := app.proxy.Environment(""); len(proxyEnv) > 0 {
+		cmd.Env = append(os.Environ(), proxyEnv...)
+	}
 	var out, stderr bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
@@ -4,10 +4,12 @@ import (
 	"html/template"
 	"path/filepath"
 	"time"
+
+	"github.com/tobiasdenzler/checkbot/pkg/healthcheck"
 )
 
 type templateData struct {
-	Checklist     map[string]*Check
+	Checklist     map[string]*healthcheck.Check
 	Sandbox       Sandbox
 	Configuration Configuration
 }
@@ -0,0 +1,273 @@
+package healthcheck
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Check consists of a script and a metric to scrape.
+type Check struct {
+	Name          string
+	File          string
+	Hash          string // Sha256 of the script, changes with the content
+	Interval      int
+	Active        bool
+	MetricType    string
+	Help          string
+	Encoding      string // Encoding of the label values, e.g. base64
+	StatusInfo    bool   // Expose the status message as metric
+	Raw           bool   // Expose the metrics on the raw metrics endpoint
+	Priority      string // Checks with low priority are delayed under load
+	Owner         string // Owner of the check
+	Team          string // Team owning the check
+	RunbookURL    string // Runbook describing how to handle a failing check
+	Proxy         string `json:"-"` // Proxy used by the script, overrides the global proxy, may contain a password
+	proxyEnv      []string
+	Drift         bool   // Compare the result against the previous run or golden file
+	Golden        string // File with the expected result of the check
+	driftBaseline map[string]float64
+	driftMetric   *prometheus.GaugeVec
+	registerer    prometheus.Registerer // Registry for the metrics of the check
+	Message       string                // Status message of the last run
+	statusMetric  *prometheus.GaugeVec
+	metric        interface{}
+	resultLast    []map[string]string // Metric vectors of the last run
+	resultCurrent []map[string]string // Metric vectors of the current run
+	stoppedchan   chan struct{}
+	Offset        int64
+	Nextrun       int64
+	Success       int
+	Failures      int   // Number of failures in a row
+	Silenced      int64 // Check will not run until this time
+}
+
+// Define the metadata that can be used in the scripts
+const metaActive = "ACTIVE"
+const metaType = "TYPE"
+const metaHelp = "HELP"
+const metaInterval = "INTERVAL"
+const metaEncoding = "ENCODING"
+const metaStatusInfo = "STATUSINFO"
+const metaRaw = "RAW"
+const metaPriority = "PRIORITY"
+const metaOwner = "OWNER"
+const metaTeam = "TEAM"
+const metaRunbook = "RUNBOOK"
+const metaProxy = "PROXY"
+const metaDrift = "DRIFT"
+const metaGolden = "GOLDEN"
+
+// Extension of golden files used for drift detection
+const goldenExtension = ".golden"
+
+// NewCheck creates a check running a script, the first run is randomly deferred.
+// The interval must be at least one second.
+func NewCheck(name string, file string, metricType string, help string, interval int) (*Check, error) {
+	if interval < 1 {
+		return nil, fmt.Errorf("Invalid %s %d for check %s", metaInterval, interval, name)
+	}
+
+	offset := randomOffset(interval) // Add random offset to defer execution
+	return &Check{
+		Name:          name,
+		File:          file,
+		Interval:      interval,
+		Active:        true,
+		MetricType:    metricType,
+		Help:          help,
+		resultLast:    []map[string]string{},
+		resultCurrent: []map[string]string{},
+		Offset:        offset,
+		Nextrun:       time.Now().Unix() + offset,
+		Success:       -1, // not yet run
+	}, nil
+}
+
+// LoadChecks reads all the available scripts and creates a list of checks.
+// Scripts with invalid metadata are skipped and returned as error.
+func LoadChecks(scriptBase string, metricsPrefix string, proxy ProxyConfig) (map[string]*Check, error) {
+
+	checkList := map[string]*Check{}
+	errs := []error{}
+
+	// Walk through all scripts and register the files with a handler
+	err := filepath.Walk(scriptBase, func(path string, info os.FileInfo, err error) error {
+
+		// Check if we have a file
+		if info != nil && !info.IsDir() {
+
+			// Openshift is using linking of files with .., golden files are no checks
+			if !strings.Contains(path, "..") && filepath.Ext(path) != goldenExtension {
+
+				// Retrieve the status as bool
+				active, _ := strconv.ParseBool(extractMetadataFromFile(metaActive, path))
+
+				// Retrieve the optional status info as bool
+				statusInfo, _ := strconv.ParseBool(extractOptionalMetadataFromFile(metaStatusInfo, path))
+
+				// Retrieve the optional raw endpoint as bool
+				raw, _ := strconv.ParseBool(extractOptionalMetadataFromFile(metaRaw, path))
+
+				// Retrieve the optional proxy
+				proxyMeta := extractOptionalMetadataFromFile(metaProxy, path)
+
+				// Retrieve the optional drift detection as bool, a golden file implies drift detection
+				golden := extractOptionalMetadataFromFile(metaGolden, path)
+				drift, _ := strconv.ParseBool(extractOptionalMetadataFromFile(metaDrift, path))
+				drift = drift || golden != ""
+
+				// Retrieve the interval as integer, the script is skipped if it is invalid
+				intervalMeta := extractMetadataFromFile(metaInterval, path)
+				interval, err := strconv.Atoi(intervalMeta)
+				if err != nil || interval < 1 {
+					errs = append(errs, fmt.Errorf("Invalid %s '%s' in file %s", metaInterval, intervalMeta, path))
+					return nil
+				}
+
+				// Hash the content to detect changes of the script
+				hash, err := hashFile(path)
+				if err != nil {
+					errs = append(errs, err)
+					return nil
+				}
+
+				// Create a new check
+				offset := randomOffset(interval) // Add random offset to defer execution
+				check := &Check{
+					Name:          metricsPrefix + "_" + strings.Split(info.Name(), ".")[0], // Remove file ending
+					File:          path,
+					Hash:          hash,
+					Interval:      interval,
+					Active:        active,
+					MetricType:    extractMetadataFromFile(metaType, path),
+					Help:          extractMetadataFromFile(metaHelp, path),
+					Encoding:      extractOptionalMetadataFromFile(metaEncoding, path),
+					StatusInfo:    statusInfo,
+					Raw:           raw,
+					Priority:      extractOptionalMetadataFromFile(metaPriority, path),
+					Owner:         extractOptionalMetadataFromFile(metaOwner, path),
+					Team:          extractOptionalMetadataFromFile(metaTeam, path),
+					RunbookURL:    extractOptionalMetadataFromFile(metaRunbook, path),
+					Proxy:         proxyMeta,
+					proxyEnv:      proxy.Environment(proxyMeta),
+					Drift:         drift,
+					Golden:        golden,
+					resultLast:    []map[string]string{},
+					resultCurrent: []map[string]string{},
+					Offset:        offset,
+					Nextrun:       time.Now().Unix() + offset,
+					Success:       -1, // not yet run
+				}
+
+				// Add the check to the list
+				checkList[check.Name] = check
+				log.Infof("Add check %s and schedule first run for %s", check.Name, time.Unix(check.Nextrun, 0))
+				log.Debugf("Check details: %s", check.String())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed to read the scripts: %w", err))
+	}
+
+	return checkList, errors.Join(errs...)
+}
+
+// Return the sha256 of a file as hex string.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Return a random offset in seconds to defer the first run of a check.
+func randomOffset(interval int) int64 {
+	if interval <= 1 {
+		return 0
+	}
+	return int64(rand.Intn(interval - 1))
+}
+
+// Extract metadata information from a script.
+// Metadata can be added using e.g. # TYPE
+func extractMetadataFromFile(metadata string, file string) string {
+	line, err := findLineInFile(file, "# "+metadata)
+	if err == nil {
+		return strings.TrimSpace(strings.Split(line, "# "+metadata)[1])
+	}
+	log.Errorf("Failed to retrieve %s from file %s", metadata, file)
+	return ""
+}
+
+// Extract optional metadata information from a script.
+// Returns an empty string if the metadata is not found.
+func extractOptionalMetadataFromFile(metadata string, file string) string {
+	line, err := findLineInFile(file, "# "+metadata)
+	if err == nil {
+		return strings.TrimSpace(strings.Split(line, "# "+metadata)[1])
+	}
+	log.Debugf("No optional %s found in file %s", metadata, file)
+	return ""
+}
+
+// Search for a string in a file and return the corresponding line.
+func findLineInFile(path string, searchFor string) (string, error) {
+
+	// Open file for reading
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Splits on newlines by default.
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), searchFor) {
+			return scanner.Text(), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("Failed to find " + searchFor + " in file " + path + ".")
+}
+
+// String returns the Check as string.
+func (c Check) String() string {
+	return fmt.Sprintf(
+		"[%s : %s : %d : %v : %s : %s : %s]",
+		c.Name,
+		c.File,
+		c.Interval,
+		c.Active,
+		c.MetricType,
+		c.Help,
+		c.Encoding)
+}
@@ -0,0 +1,46 @@
+package healthcheck
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFindLineInFile(t *testing.T) {
+	line, err := findLineInFile("../../test/scripts/single_result.sh", "# TYPE")
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if line != "" {
+		fmt.Println(line)
+	} else {
+		t.Error("empty result")
+	}
+}
+
+type testpairInterval struct {
+	interval int
+	hasError bool
+}
+
+var testsInterval = []testpairInterval{
+	{-1, true},
+	{0, true},
+	{1, false},
+	{2, false},
+	{60, false},
+}
+
+func TestNewCheckInterval(t *testing.T) {
+	for _, pair := range testsInterval {
+		check, err := NewCheck("test_interval", "placeholder", "Gauge", "placeholder", pair.interval)
+
+		if (err != nil) != pair.hasError {
+			t.Errorf("Unexpected error for interval %d: %v", pair.interval, err)
+		}
+		if err == nil && (check.Offset < 0 || check.Offset >= int64(pair.interval)) {
+			t.Errorf("Unexpected offset %d for interval %d", check.Offset, pair.interval)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Package healthcheck runs check scripts on a schedule and exports their
+// results as Prometheus metrics.
+//
+// A check is a script returning one line per metric vector in the format
+// value|label1=value1,label2=value2. The behaviour of a check is configured
+// by metadata comments in the script, e.g. # TYPE Gauge or # INTERVAL 60.
+//
+// Checks can be loaded from a directory of scripts or added programmatically:
+//
+//	rawRegistry := prometheus.NewRegistry()
+//	scheduler := healthcheck.NewScheduler(healthcheck.Options{
+//		ScriptBase:      "scripts",
+//		MetricsPrefix:   "mytool",
+//		LogFailureEvery: 10,
+//		RawRegisterer:   rawRegistry,
+//	})
+//	if err := scheduler.Load(); err != nil {
+//		log.Print(err) // Scripts with invalid metadata are skipped
+//	}
+//	check, err := healthcheck.NewCheck("mytool_ping", "/opt/ping.sh", "Gauge", "Ping the api.", 60)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if err := scheduler.AddCheck(check); err != nil {
+//		log.Fatal(err)
+//	}
+//	scheduler.Start()
+//	defer scheduler.Stop()
+//
+//	http.Handle("/metrics", promhttp.Handler())
+//	http.Handle("/metrics/raw", promhttp.HandlerFor(rawRegistry, promhttp.HandlerOpts{}))
+//
+// Checks added with AddCheck are kept on Reload and start immediately if the
+// scheduler is already running.
+//
+// Metrics are registered in Options.Registerer, the default Prometheus
+// registry if nil, except for checks with # RAW true which use
+// Options.RawRegisterer. The status metrics are named after the
+// StatusPrefix, or the MetricsPrefix if empty, e.g. mytool_lastrun_info.
+package healthcheck
@@ -0,0 +1,125 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Compare the result of a check against the previous run or the golden file
+// and update the drift metric.
+func detectDrift(check *Check, results map[string]float64) {
+	baseline := check.driftBaseline
+	if check.Golden != "" {
+		var err error
+		baseline, err = loadGoldenFile(goldenPath(check), check.Encoding)
+		if err != nil {
+			log.Warnf("Failed to load golden file for check %s: %v", check.Name, err)
+			return
+		}
+	} else {
+		check.driftBaseline = results
+	}
+
+	// First run without golden file has nothing to compare
+	if baseline == nil {
+		log.Debugf("Check %s has no baseline for drift detection yet", check.Name)
+		return
+	}
+
+	added, removed, changed := compareResults(baseline, results)
+	if added+removed+changed > 0 {
+		log.Infof("Check %s detected drift: %d added, %d removed, %d changed", check.Name, added, removed, changed)
+	}
+
+	registerDriftMetricForCheck(check, added, removed, changed)
+}
+
+// Compare two results and count the added, removed and changed metric vectors.
+func compareResults(baseline map[string]float64, current map[string]float64) (int, int, int) {
+	added, removed, changed := 0, 0, 0
+	for key, value := range current {
+		baselineValue, ok := baseline[key]
+		if !ok {
+			added++
+		} else if baselineValue != value {
+			changed++
+		}
+	}
+	for key := range baseline {
+		if _, ok := current[key]; !ok {
+			removed++
+		}
+	}
+	return added, removed, changed
+}
+
+// Return the key identifying a result by its labels.
+// The sorted label pairs are encoded as json, so delimiters in values are unambiguous.
+func resultKey(labels map[string]string) string {
+	pairs := make([][2]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, [2]string{key, value})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+
+	key, _ := json.Marshal(pairs) // Cannot fail for strings
+	return string(key)
+}
+
+// Return the path of the golden file, relative paths are based on the script directory.
+func goldenPath(check *Check) string {
+	if filepath.IsAbs(check.Golden) {
+		return check.Golden
+	}
+	return filepath.Join(filepath.Dir(check.File), check.Golden)
+}
+
+// Load the expected result of a check from a golden file.
+// The file uses the same format as the result of a script.
+func loadGoldenFile(path string, encoding string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]float64{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			value, labels := convertResult(line)
+			labels = decodeLabels(labels, encoding)
+			results[resultKey(labels)] = value
+		}
+	}
+	return results, nil
+}
+
+// Register the drift metric for a given check.
+func registerDriftMetricForCheck(check *Check, added int, removed int, changed int) {
+	if check.driftMetric == nil {
+		check.driftMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        check.Name + "_drift",
+				Help:        "Provides the number of added, removed and changed results of " + check.Name + ".",
+				ConstLabels: constLabelsForCheck(check),
+			},
+			[]string{"change"},
+		)
+
+		err := registererForCheck(check).Register(check.driftMetric)
+		if err != nil {
+			log.Warnf("Not able to register drift metric for check %s: %v", check.Name, err)
+			check.driftMetric = nil
+			return
+		}
+	}
+
+	check.driftMetric.With(prometheus.Labels{"change": "added"}).Set(float64(added))
+	check.driftMetric.With(prometheus.Labels{"change": "removed"}).Set(float64(removed))
+	check.driftMetric.With(prometheus.Labels{"change": "changed"}).Set(float64(changed))
+}
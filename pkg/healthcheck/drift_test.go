@@ -0,0 +1,77 @@
+package healthcheck
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCompareResults(t *testing.T) {
+
+	baseline := map[string]float64{"user:a": 1, "user:b": 1, "user:c": 1}
+	current := map[string]float64{"user:a": 1, "user:b": 2, "user:d": 1, "user:e": 1}
+
+	added, removed, changed := compareResults(baseline, current)
+	if added != 2 || removed != 1 || changed != 1 {
+		t.Errorf("Expected 2 added, 1 removed, 1 changed but found %d, %d, %d", added, removed, changed)
+	}
+}
+
+func TestResultKey(t *testing.T) {
+
+	// Delimiters in label values must not produce the same key
+	testpairs := [][2]map[string]string{
+		{{"a": "1,b:2"}, {"a": "1", "b": "2"}},
+		{{"a:b": "c"}, {"a": "b:c"}},
+		{{"a": ""}, {}},
+	}
+
+	for _, pair := range testpairs {
+		if resultKey(pair[0]) == resultKey(pair[1]) {
+			t.Errorf("Expected different keys for %v and %v", pair[0], pair[1])
+		}
+	}
+
+	if resultKey(map[string]string{"a": "1", "b": "2"}) != resultKey(map[string]string{"b": "2", "a": "1"}) {
+		t.Error("Expected same key independent of the order of the labels")
+	}
+}
+
+func TestDetectDrift(t *testing.T) {
+
+	check := getPlaceholderCheck("test_drift", "Gauge")
+	check.Drift = true
+
+	// First run has no baseline
+	admin := resultKey(map[string]string{"subject": "system:admin"})
+	developer := resultKey(map[string]string{"subject": "developer"})
+	detectDrift(check, map[string]float64{admin: 1})
+	if check.driftMetric != nil {
+		t.Error("Expected no drift metric without baseline")
+	}
+
+	// Second run is compared against the first run
+	detectDrift(check, map[string]float64{admin: 1, developer: 1})
+	if countMetrics(t, prometheus.DefaultGatherer, "test_drift_drift") != 3 {
+		t.Error("Expected drift metric with added, removed and changed")
+	}
+
+	unregisterMetricsForCheck(check)
+}
+
+func TestLoadGoldenFile(t *testing.T) {
+
+	check := getPlaceholderCheck("test_golden", "Gauge")
+	check.File = "../../test/scripts/single_result.sh"
+	check.Golden = "drift_result.golden"
+
+	results, err := loadGoldenFile(goldenPath(check), "")
+	if err != nil {
+		t.Error(err)
+	}
+
+	added, removed, changed := compareResults(results, map[string]float64{resultKey(map[string]string{"subject": "system:admin"}): 1})
+	if added != 0 || removed != 1 || changed != 0 {
+		t.Errorf("Expected 1 removed but found %d added, %d removed, %d changed", added, removed, changed)
+	}
+}
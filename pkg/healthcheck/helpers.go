@@ -0,0 +1,14 @@
+package healthcheck
+
+import (
+	"strings"
+)
+
+// mapToString will convert a map to a string.
+func mapToString(m map[string]string) string {
+	tmp := ""
+	for key, value := range m {
+		tmp += key + ":" + value + ","
+	}
+	return strings.TrimSuffix(tmp, ",")
+}
@@ -0,0 +1,299 @@
+package healthcheck
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Cgroup files providing the cpu limit and usage of the container
+const cgroupV2CPUMax = "/sys/fs/cgroup/cpu.max"
+const cgroupV2CPUStat = "/sys/fs/cgroup/cpu.stat"
+const cgroupV1CPUQuota = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+const cgroupV1CPUPeriod = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+const cgroupV1CPUUsage = "/sys/fs/cgroup/cpuacct/cpuacct.usage"
+
+// Interval to measure the cpu usage
+const loadSamplingInterval = 5 * time.Second
+
+// Delay for low priority checks while shedding load
+const loadSheddingDelay = 30
+
+// Priority of checks that can be delayed under load
+const priorityLow = "low"
+
+// LoadShedder limits the concurrent execution of checks based on the cpu limit.
+type LoadShedder struct {
+	cpuLimit       float64       // Number of cpus, 0 if unlimited
+	threshold      float64       // Ratio of the cpu limit to start shedding load
+	slots          chan struct{} // Concurrent executions
+	pressureSlot   chan struct{} // Single execution under pressure
+	pressure       atomic.Bool
+	sheddingMetric prometheus.Gauge
+	usageMetric    prometheus.Gauge
+	delayedMetric  *prometheus.CounterVec
+	stopchan       chan struct{} // A channel to tell it to stop
+	stoppedchan    chan struct{} // Closed when the measuring has stopped
+}
+
+// NewLoadShedder creates a new load shedder and registers its metrics.
+// If maxConcurrent is 0 the number of concurrent checks is derived from the cpu limit.
+// The metrics are named after the metricsPrefix and registered in the default registry if registerer is nil.
+func NewLoadShedder(maxConcurrent int, threshold float64, metricsPrefix string, registerer prometheus.Registerer) (*LoadShedder, error) {
+	if metricsPrefix == "" {
+		metricsPrefix = "checkbot"
+	}
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	cpuLimit, err := DetectCPULimit()
+	if err != nil {
+		log.Debugf("No cpu limit detected: %v", err)
+	}
+
+	cpus := runtime.NumCPU()
+	if cpuLimit > 0 {
+		cpus = int(math.Ceil(cpuLimit))
+	}
+
+	if maxConcurrent <= 0 {
+		maxConcurrent = cpus
+	}
+	log.Infof("Running at most %d checks concurrently", maxConcurrent)
+
+	ls := &LoadShedder{
+		cpuLimit:     cpuLimit,
+		threshold:    threshold,
+		slots:        make(chan struct{}, maxConcurrent),
+		pressureSlot: make(chan struct{}, 1),
+		sheddingMetric: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: metricsPrefix + "_loadshedding_active",
+				Help: "Provides information if load shedding is active because of cpu pressure.",
+			},
+		),
+		usageMetric: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: metricsPrefix + "_cpu_usage_ratio",
+				Help: "Provides the cpu usage as ratio of the cpu limit.",
+			},
+		),
+		delayedMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: metricsPrefix + "_loadshedding_delayed_total",
+				Help: "Provides the number of runs delayed because of load shedding.",
+			},
+			[]string{"name"},
+		),
+	}
+
+	// Do not leave some of the metrics registered if one fails
+	collectors := []prometheus.Collector{ls.sheddingMetric, ls.usageMetric, ls.delayedMetric}
+	for i, collector := range collectors {
+		err := registerer.Register(collector)
+		if err != nil {
+			for _, registered := range collectors[:i] {
+				registerer.Unregister(registered)
+			}
+			return nil, err
+		}
+	}
+
+	return ls, nil
+}
+
+// Start measures the cpu usage in the background until Stop is called.
+func (ls *LoadShedder) Start() {
+	if ls.cpuLimit == 0 || ls.threshold <= 0 {
+		log.Info("Load shedding is disabled")
+		return
+	}
+	if ls.stopchan != nil {
+		return
+	}
+
+	ls.stopchan = make(chan struct{})
+	ls.stoppedchan = make(chan struct{})
+	go ls.measure(ls.stopchan, ls.stoppedchan)
+}
+
+// Stop stops measuring the cpu usage and waits until it is finished.
+// Load is no longer shed after stopping.
+func (ls *LoadShedder) Stop() {
+	if ls.stopchan == nil {
+		return
+	}
+
+	close(ls.stopchan)
+	<-ls.stoppedchan
+	ls.stopchan = nil
+
+	ls.pressure.Store(false)
+	ls.sheddingMetric.Set(0)
+}
+
+// Measure the cpu usage regularly and update the pressure.
+func (ls *LoadShedder) measure(stopchan chan struct{}, stoppedchan chan struct{}) {
+
+	// Close the stoppedchan when this func exits
+	defer close(stoppedchan)
+
+	lastUsage, err := readCPUUsage()
+	if err != nil {
+		log.Warnf("Load shedding is disabled, failed to read cpu usage: %v", err)
+		return
+	}
+	lastTime := time.Now()
+
+	ticker := time.NewTicker(loadSamplingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopchan:
+			log.Debug("Stopping load shedding")
+			return
+
+		case <-ticker.C:
+			usage, err := readCPUUsage()
+			if err != nil {
+				log.Warnf("Failed to read cpu usage: %v", err)
+				continue
+			}
+			now := time.Now()
+
+			ratio := (usage - lastUsage).Seconds() / (now.Sub(lastTime).Seconds() * ls.cpuLimit)
+			lastUsage, lastTime = usage, now
+
+			pressure := ratio >= ls.threshold
+			if pressure != ls.pressure.Load() {
+				if pressure {
+					log.Warnf("Cpu usage at %.0f%% of limit, start shedding load", ratio*100)
+				} else {
+					log.Infof("Cpu usage at %.0f%% of limit, stop shedding load", ratio*100)
+				}
+			}
+			ls.pressure.Store(pressure)
+
+			ls.usageMetric.Set(ratio)
+			if pressure {
+				ls.sheddingMetric.Set(1)
+			} else {
+				ls.sheddingMetric.Set(0)
+			}
+		}
+	}
+}
+
+// Check if the run of a check should be delayed because of cpu pressure.
+func (ls *LoadShedder) shed(check *Check) bool {
+	if ls == nil || check.Priority != priorityLow || !ls.pressure.Load() {
+		return false
+	}
+	ls.delayedMetric.With(prometheus.Labels{"name": check.Name}).Inc()
+	return true
+}
+
+// Wait for a free slot to run a check and return a func to release it.
+// Under cpu pressure only one check is running at a time.
+func (ls *LoadShedder) acquire() func() {
+	if ls == nil {
+		return func() {}
+	}
+
+	ls.slots <- struct{}{}
+	if !ls.pressure.Load() {
+		return func() { <-ls.slots }
+	}
+
+	ls.pressureSlot <- struct{}{}
+	return func() {
+		<-ls.pressureSlot
+		<-ls.slots
+	}
+}
+
+// DetectCPULimit returns the cpu limit of the container from the cgroup, 0 if unlimited.
+func DetectCPULimit() (float64, error) {
+	data, err := os.ReadFile(cgroupV2CPUMax)
+	if err == nil {
+		return parseCPUMax(string(data))
+	}
+
+	quota, err := readIntFromFile(cgroupV1CPUQuota)
+	if err != nil {
+		return 0, err
+	}
+	period, err := readIntFromFile(cgroupV1CPUPeriod)
+	if err != nil {
+		return 0, err
+	}
+	if quota <= 0 || period <= 0 {
+		return 0, nil
+	}
+	return float64(quota) / float64(period), nil
+}
+
+// Parse the content of cpu.max, e.g. "20000 100000" or "max 100000".
+func parseCPUMax(content string) (float64, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return 0, errors.New("Invalid format of cpu.max: " + content)
+	}
+	if fields[0] == "max" {
+		return 0, nil
+	}
+
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	if period <= 0 {
+		return 0, errors.New("Invalid period in cpu.max: " + content)
+	}
+	return quota / period, nil
+}
+
+// Read the total cpu usage of the container from the cgroup.
+func readCPUUsage() (time.Duration, error) {
+	f, err := os.Open(cgroupV2CPUStat)
+	if err == nil {
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				usage, err := strconv.ParseInt(fields[1], 10, 64)
+				return time.Duration(usage) * time.Microsecond, err
+			}
+		}
+		return 0, errors.New("Failed to find usage_usec in " + cgroupV2CPUStat)
+	}
+
+	usage, err := readIntFromFile(cgroupV1CPUUsage)
+	return time.Duration(usage) * time.Nanosecond, err
+}
+
+// Read a single integer from a file.
+func readIntFromFile(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,184 @@
+package healthcheck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type testpairCPUMax struct {
+	content  string
+	limit    float64
+	hasError bool
+}
+
+var testsCPUMax = []testpairCPUMax{
+	{"max 100000\n", 0, false},
+	{"20000 100000\n", 0.2, false},
+	{"150000 100000", 1.5, false},
+	{"", 0, true},
+	{"abc 100000", 0, true},
+	{"20000 0", 0, true},
+}
+
+func TestParseCPUMax(t *testing.T) {
+	for _, pair := range testsCPUMax {
+		limit, err := parseCPUMax(pair.content)
+
+		if (err != nil) != pair.hasError {
+			t.Errorf("Unexpected error for %q: %v", pair.content, err)
+		}
+		if limit != pair.limit {
+			t.Errorf("Expected cpu limit %f but found %f", pair.limit, limit)
+		}
+	}
+}
+
+// Create a load shedder for testing without a cpu limit.
+func getPlaceholderLoadShedder(slots int) *LoadShedder {
+	return &LoadShedder{
+		slots:          make(chan struct{}, slots),
+		pressureSlot:   make(chan struct{}, 1),
+		sheddingMetric: prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_loadshedding_active"}),
+		usageMetric:    prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_cpu_usage_ratio"}),
+		delayedMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: "test_loadshedding_delayed_total"},
+			[]string{"name"},
+		),
+	}
+}
+
+func TestShedLowPriority(t *testing.T) {
+
+	ls := getPlaceholderLoadShedder(2)
+
+	check := getPlaceholderCheck("test_shed", "Gauge")
+	check.Priority = priorityLow
+	other := getPlaceholderCheck("test_shed_normal", "Gauge")
+
+	if ls.shed(check) {
+		t.Error("Expected no load shedding without pressure")
+	}
+
+	ls.pressure.Store(true)
+
+	if !ls.shed(check) {
+		t.Error("Expected low priority check to be delayed under pressure")
+	}
+	if ls.shed(other) {
+		t.Error("Expected normal priority check not to be delayed under pressure")
+	}
+
+	delayed := testCounterValue(t, ls.delayedMetric.WithLabelValues("test_shed"))
+	if delayed != 1 {
+		t.Errorf("Expected 1 delayed run but found %f", delayed)
+	}
+}
+
+func TestAcquire(t *testing.T) {
+
+	ls := getPlaceholderLoadShedder(2)
+
+	// Without pressure two checks can run concurrently
+	release1 := ls.acquire()
+	release2 := ls.acquire()
+	if len(ls.slots) != 2 {
+		t.Error("Expected two slots to be used")
+	}
+	release1()
+	release2()
+	if len(ls.slots) != 0 {
+		t.Error("Expected slots to be released")
+	}
+
+	// Under pressure only one check can run at a time
+	ls.pressure.Store(true)
+	release1 = ls.acquire()
+
+	acquired := make(chan func())
+	go func() {
+		acquired <- ls.acquire()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected second check to wait under pressure")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release1()
+
+	select {
+	case release2 = <-acquired:
+		release2()
+	case <-time.After(time.Second):
+		t.Fatal("Expected second check to run after release")
+	}
+	if len(ls.slots) != 0 || len(ls.pressureSlot) != 0 {
+		t.Error("Expected slots to be released")
+	}
+}
+
+// Return the value of a counter.
+func testCounterValue(t *testing.T, counter prometheus.Counter) float64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(counter)
+
+	families, err := registry.Gather()
+	if err != nil || len(families) != 1 {
+		t.Fatalf("Failed to gather counter: %v", err)
+	}
+	return families[0].GetMetric()[0].GetCounter().GetValue()
+}
+
+func TestNewLoadShedderRegistry(t *testing.T) {
+
+	registry := prometheus.NewRegistry()
+	_, err := NewLoadShedder(2, 0.9, "test", registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countMetrics(t, registry, "test_loadshedding_active") != 1 {
+		t.Error("Expected load shedding metric in registry")
+	}
+
+	// Registering the metrics twice returns an error instead of panicking
+	_, err = NewLoadShedder(2, 0.9, "test", registry)
+	if err == nil {
+		t.Error("Expected error for duplicate registration")
+	}
+}
+
+func TestLoadShedderStop(t *testing.T) {
+
+	ls := getPlaceholderLoadShedder(2)
+	ls.cpuLimit = 1
+	ls.threshold = 0.9
+
+	// Stopping a load shedder which is not running does nothing
+	ls.Stop()
+
+	ls.Start()
+	ls.pressure.Store(true)
+
+	done := make(chan struct{})
+	go func() {
+		ls.Stop()
+		ls.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected load shedder to stop in time")
+	}
+
+	if ls.pressure.Load() {
+		t.Error("Expected no pressure after stop")
+	}
+	if ls.stopchan != nil {
+		t.Error("Expected load shedder to be stopped")
+	}
+}
@@ -0,0 +1,96 @@
+package healthcheck
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyConfig holds the proxy used for outbound connections.
+type ProxyConfig struct {
+	URL     string // HTTP(S) or SOCKS5 proxy, e.g. http://proxy:3128 or socks5://proxy:1080
+	NoProxy string // Comma separated list of hosts, domains or CIDRs to connect directly
+}
+
+// Transport creates a transport using the proxy for all hosts not excluded by NoProxy.
+func (p ProxyConfig) Transport(tlsConfig *tls.Config) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	if p.URL == "" {
+		return transport, nil
+	}
+
+	proxyURL, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	transport.Proxy = func(r *http.Request) (*url.URL, error) {
+		if !p.useProxy(r.URL.Host) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+	return transport, nil
+}
+
+// Check if the proxy should be used to connect to a host.
+func (p ProxyConfig) useProxy(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(p.NoProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return false
+		}
+
+		// IP addresses and CIDRs
+		if ip != nil {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return false
+			}
+			if entryIP := net.ParseIP(entry); entryIP != nil && entryIP.Equal(ip) {
+				return false
+			}
+			continue
+		}
+
+		// Domains including all subdomains
+		domain := strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// Environment returns the environment variables to run a script with the proxy.
+// The proxy of a check overrides the global proxy.
+func (p ProxyConfig) Environment(checkProxy string) []string {
+	proxyURL := p.URL
+	if checkProxy != "" {
+		proxyURL = checkProxy
+	}
+	if proxyURL == "" {
+		return nil
+	}
+
+	env := []string{}
+	for _, name := range []string{"http_proxy", "https_proxy", "all_proxy"} {
+		env = append(env, name+"="+proxyURL, strings.ToUpper(name)+"="+proxyURL)
+	}
+	if p.NoProxy != "" {
+		env = append(env, "no_proxy="+p.NoProxy, "NO_PROXY="+p.NoProxy)
+	}
+	return env
+}
@@ -0,0 +1,55 @@
+package healthcheck
+
+import (
+	"testing"
+)
+
+type testpairProxy struct {
+	host     string
+	useProxy bool
+}
+
+var testsProxy = []testpairProxy{
+	{"example.com:443", true},
+	{"checkbot.svc.cluster.local:4444", false},
+	{"cluster.local", false},
+	{"api.internal.example.org", false},
+	{"internal.example.org", false},
+	{"10.1.2.3:8080", false},
+	{"192.168.1.1", true},
+	{"[fd00::1]:443", false},
+	{"[2001:db8::1]:443", true},
+}
+
+func TestUseProxy(t *testing.T) {
+
+	proxy := ProxyConfig{
+		URL:     "http://proxy:3128",
+		NoProxy: "cluster.local, .internal.example.org,10.0.0.0/8,fd00::/8",
+	}
+
+	for _, pair := range testsProxy {
+		if proxy.useProxy(pair.host) != pair.useProxy {
+			t.Errorf("Expected proxy usage %v for host %s", pair.useProxy, pair.host)
+		}
+	}
+
+	if (ProxyConfig{URL: "http://proxy:3128", NoProxy: "*"}).useProxy("example.com") {
+		t.Error("Expected no proxy usage for wildcard")
+	}
+}
+
+func TestProxyEnvironment(t *testing.T) {
+
+	proxy := ProxyConfig{URL: "http://proxy:3128", NoProxy: "cluster.local"}
+
+	if len(proxy.Environment("")) != 8 {
+		t.Errorf("Expected 8 environment variables but found %v", proxy.Environment(""))
+	}
+	if proxy.Environment("socks5://socks:1080")[0] != "http_proxy=socks5://socks:1080" {
+		t.Error("Expected proxy of check to override global proxy")
+	}
+	if (ProxyConfig{}).Environment("") != nil {
+		t.Error("Expected no environment variables without proxy")
+	}
+}
@@ -0,0 +1,499 @@
+package healthcheck
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Prefix of the output line containing the status message
+const statusPrefix = "# STATUS "
+
+// Maximum length of the status message
+const maxMessageLength = 120
+
+
+// Run the check and save the result to the list.
+func (s *Scheduler) runCheck(check *Check, stopchan chan struct{}) {
+
+	// Close the stoppedchan when this func exits
+	defer close(check.stoppedchan)
+
+	// Teardown
+	defer func() {
+		s.mutex.Lock()
+		unregisterMetricsForCheck(check)
+		s.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		default:
+
+			// Check if we can run the check
+			script, due := s.prepareRun(check)
+			if due {
+
+				// Run the script without holding the lock
+				release := s.options.LoadShedder.acquire()
+				result, err := runBashScript(script)
+				release()
+
+				snapshot := s.processResult(check, result, err)
+
+				// Notify about the result
+				if s.options.OnResult != nil {
+					s.options.OnResult(&snapshot)
+				}
+			}
+
+		case <-stopchan:
+			// Stop
+			log.Debugf("Stopping check %s", check.Name)
+			return
+
+		case <-time.After(10 * time.Second):
+			// Task didn't stop in time
+			log.Debugf("Forced stopping check %s", check.Name)
+			return
+		}
+
+		// Slow down
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// Check if the check is due and return a copy of the check to run the script.
+// Silenced checks and low priority checks under load are postponed.
+func (s *Scheduler) prepareRun(check *Check) (Check, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if time.Now().Unix() <= check.Nextrun {
+		return Check{}, false
+	}
+
+	// Skip the run while the check is silenced
+	if check.Silenced > time.Now().Unix() {
+		log.Debugf("Check %s is silenced until %s", check.Name, time.Unix(check.Silenced, 0))
+		check.Nextrun = check.Silenced
+		return Check{}, false
+	}
+
+	// Delay the run of low priority checks under load
+	if s.options.LoadShedder.shed(check) {
+		log.Infof("Delaying check %s because of cpu pressure", check.Name)
+		check.Nextrun = time.Now().Unix() + loadSheddingDelay
+		return Check{}, false
+	}
+
+	log.Debugf("Running check %s", check.Name)
+
+	// Store result of previous run
+	check.resultLast = check.resultCurrent
+	check.resultCurrent = []map[string]string{}
+
+	return *check, true
+}
+
+// Process the result of a script, update the metrics and return a copy of the check.
+func (s *Scheduler) processResult(check *Check, result string, err error) Check {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	check.Success = 0
+	check.Message = ""
+	results := map[string]float64{}
+	if err == nil {
+		check.Success = 1
+
+		if check.Failures > 0 {
+			log.Infof("Check %s recovered after %d failures", check.Name, check.Failures)
+			check.Failures = 0
+		}
+
+		// Split the result from the check script, can be multiple lines
+		resultLine := strings.Split(result, "\n")
+		for _, line := range resultLine {
+			if strings.HasPrefix(line, statusPrefix) {
+				// Status message of the check
+				check.Message = sanitizeMessage(strings.TrimPrefix(line, statusPrefix))
+			} else if line != "" {
+				// Extract values from the result and register the metric
+				value, labels := convertResult(line)
+				labels = decodeLabels(labels, check.Encoding)
+				registerMetricsForCheck(check, value, labels)
+				results[resultKey(labels)] = value
+			}
+		}
+
+	} else {
+		check.Message = sanitizeMessage(err.Error())
+		check.Failures++
+
+		// Only log the first and every nth failure in a row
+		if shouldLogFailure(check.Failures, s.options.LogFailureEvery) {
+			log.Warnf("Check %s failed %d times in a row with error: %s", check.Name, check.Failures, err)
+		}
+	}
+
+	// Compare the result against the baseline
+	if check.Drift && err == nil {
+		detectDrift(check, results)
+	}
+
+	// Update the status info metric
+	if check.StatusInfo {
+		registerStatusMetricForCheck(check)
+	}
+
+	// Cleanup stale metrics data
+	cleanupUnusedDimensions(check)
+
+	// Set time for next run
+	check.Nextrun = check.Nextrun + int64(check.Interval) + check.Offset
+	log.Debugf("Finished check %s and schedule next run for %s", check.Name, time.Unix(check.Nextrun, 0))
+
+	// Update lastrun metric
+	lastStatusLabels := statusLabels(check)
+
+	s.lastrunMetric.With(lastStatusLabels).Set(float64(time.Now().Unix()))
+	s.lastresultMetric.With(lastStatusLabels).Set(float64(check.Success))
+	s.failuresMetric.With(lastStatusLabels).Set(float64(check.Failures))
+	s.silencedMetric.With(lastStatusLabels).Set(0)
+
+	log.Debugf("lastresult is %v", check.Success)
+	log.Debugf("Adding lastStatusLabels for %s with values %v", check.Name, lastStatusLabels)
+
+	return *check
+}
+
+// Return the labels of the status metrics for a given check.
+func statusLabels(check *Check) map[string]string {
+	labels := make(map[string]string)
+	labels["name"] = check.Name
+	labels["interval"] = strconv.Itoa(check.Interval)
+	labels["offset"] = strconv.FormatInt(check.Offset, 10)
+	labels["type"] = check.MetricType
+	labels["owner"] = check.Owner
+	labels["team"] = check.Team
+	labels["runbook_url"] = check.RunbookURL
+	return labels
+}
+
+// Check if a failure should be logged, the first and every nth failure in a row is logged.
+func shouldLogFailure(failures int, every int) bool {
+	return failures == 1 || (every > 0 && failures%every == 0)
+}
+
+// Register all metrics from Prometheus for a given check.
+func registerMetricsForCheck(check *Check, value float64, labels map[string]string) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Warnf("Not able to register metric for check %s. Maybe already registered?", check.Name)
+		}
+	}()
+
+	// Store the result labels
+	check.resultCurrent = append(check.resultCurrent, labels)
+
+	switch check.MetricType {
+	case "Gauge":
+		if check.metric == nil {
+			check.metric = prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name:        check.Name,
+					Help:        check.Help,
+					ConstLabels: constLabelsForCheck(check),
+				},
+				convertMapKeysToSlice(labels),
+			)
+
+			// This can be panicking and will be recovered
+			registererForCheck(check).MustRegister(check.metric.(*prometheus.GaugeVec))
+		}
+		check.metric.(*prometheus.GaugeVec).With(labels).Set(value)
+	case "Counter":
+		if check.metric == nil {
+			check.metric = prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name:        check.Name,
+					Help:        check.Help,
+					ConstLabels: constLabelsForCheck(check),
+				},
+				convertMapKeysToSlice(labels),
+			)
+
+			// This can be panicking and will be recovered
+			registererForCheck(check).MustRegister(check.metric.(*prometheus.CounterVec))
+		}
+		check.metric.(*prometheus.CounterVec).With(labels).Add(value)
+	case "Histogram":
+		log.Warn("Metric type Counter not implemented yet!")
+	case "Summary":
+		log.Warn("Metric type Counter not implemented yet!")
+	default:
+		log.Warnf("Not able to register unknown metric type %s", check.MetricType)
+		check.metric = nil
+	}
+
+	log.Tracef("Result from check %s -> value: %f, labels: %v", check.Name, value, mapToString(labels))
+}
+
+// Return the registry to use for the metrics of a given check.
+// The registry is set when the check is added to a scheduler.
+func registererForCheck(check *Check) prometheus.Registerer {
+	if check.registerer == nil {
+		return prometheus.DefaultRegisterer
+	}
+	return check.registerer
+}
+
+// Return the ownership of a check as constant labels.
+// Labels are only added if the metadata is defined in the script.
+func constLabelsForCheck(check *Check) prometheus.Labels {
+	labels := prometheus.Labels{}
+	if check.Owner != "" {
+		labels["owner"] = check.Owner
+	}
+	if check.Team != "" {
+		labels["team"] = check.Team
+	}
+	if check.RunbookURL != "" {
+		labels["runbook_url"] = check.RunbookURL
+	}
+	return labels
+}
+
+// Register the status info metric for a given check.
+// The metric only contains the message of the last run.
+func registerStatusMetricForCheck(check *Check) {
+	if check.statusMetric == nil {
+		check.statusMetric = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        check.Name + "_status_info",
+				Help:        "Provides the status message of the last run of " + check.Name + ".",
+				ConstLabels: constLabelsForCheck(check),
+			},
+			[]string{"message"},
+		)
+
+		err := registererForCheck(check).Register(check.statusMetric)
+		if err != nil {
+			log.Warnf("Not able to register status metric for check %s: %v", check.Name, err)
+			check.statusMetric = nil
+			return
+		}
+	}
+
+	// Remove the message of the previous run
+	check.statusMetric.Reset()
+	check.statusMetric.With(prometheus.Labels{"message": check.Message}).Set(float64(check.Success))
+}
+
+// Sanitize a status message to be used as label value.
+// Control characters are removed and the message is truncated.
+func sanitizeMessage(message string) string {
+	message = strings.ToValidUTF8(message, "")
+	message = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, message)
+	message = strings.Join(strings.Fields(message), " ")
+
+	runes := []rune(message)
+	if len(runes) > maxMessageLength {
+		return string(runes[:maxMessageLength-3]) + "..."
+	}
+	return message
+}
+
+// Cleanup metric vectors we do not need anymore.
+func cleanupUnusedDimensions(check *Check) {
+
+	log.Tracef("Check %s cleaning up -> size of resultLast : %d, size of resultCurrent: %d", check.Name, len(check.resultLast), len(check.resultCurrent))
+
+	// Loop through labels from last run and check if they are still valid for
+	// the current run, otherwise remove them.
+	var remove bool
+	for _, labelsLast := range check.resultLast {
+		remove = true
+
+		if len(check.resultCurrent) > 0 {
+			for _, labelCurrent := range check.resultCurrent {
+				if reflect.DeepEqual(labelsLast, labelCurrent) {
+					remove = false
+				}
+			}
+		}
+
+		// Remove the stale metric
+		if remove {
+			log.Debugf("Check %s remove stale metric vector with labels %s", check.Name, mapToString(labelsLast))
+
+			switch check.MetricType {
+			case "Gauge":
+				if !(check.metric.(*prometheus.GaugeVec).Delete(labelsLast)) {
+					log.Warnf("Failed to delete stale metric vector with label %s from check %s", mapToString(labelsLast), check.Name)
+				}
+			case "Counter":
+				if !(check.metric.(*prometheus.CounterVec).Delete(labelsLast)) {
+					log.Warnf("Failed to delete stale metric vector with label %s from check %s", mapToString(labelsLast), check.Name)
+				}
+			default:
+				log.Warnf("Not able to remove unknown metric type %s", check.MetricType)
+			}
+		}
+	}
+}
+
+// Unregister all metrics from Prometheus for a given check.
+func unregisterMetricsForCheck(check *Check) {
+	if check.metric != nil {
+		switch check.MetricType {
+		case "Gauge":
+			registererForCheck(check).Unregister(check.metric.(*prometheus.GaugeVec))
+		case "Counter":
+			registererForCheck(check).Unregister(check.metric.(*prometheus.CounterVec))
+		case "Histogram":
+			log.Warn("Metric type Counter not implemented yet!")
+		case "Summary":
+			log.Warn("Metric type Counter not implemented yet!")
+		default:
+			log.Warnf("Not able to unregister unknown metric type %s", check.MetricType)
+		}
+		check.metric = nil
+
+		log.Debugf("Unregistered metrics for check %s", check.Name)
+	}
+	if check.driftMetric != nil {
+		registererForCheck(check).Unregister(check.driftMetric)
+		check.driftMetric = nil
+
+		log.Debugf("Unregistered drift metric for check %s", check.Name)
+	}
+	if check.statusMetric != nil {
+		registererForCheck(check).Unregister(check.statusMetric)
+		check.statusMetric = nil
+
+		log.Debugf("Unregistered status metric for check %s", check.Name)
+	}
+}
+
+// Run the check and return the result.
+func runBashScript(check Check) (string, error) {
+
+	log.Debugf("Execute shell script: %s", check.File)
+
+	// Execute bash script
+	cmd := exec.Command(check.File)
+	if len(check.proxyEnv) > 0 {
+		cmd.Env = append(os.Environ(), check.proxyEnv...)
+	}
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	scriptResult := out.String()
+	scriptError := stderr.String()
+
+	if err != nil {
+		// Check failed with defined message
+		if scriptResult != "" {
+			log.Debugf("Script %s failed with output: %v", check.File, scriptResult)
+			return "", errors.New("Script failed with error: " + scriptResult)
+		}
+
+		// Check has error
+		if scriptError != "" {
+			log.Debugf("Script %s failed with error: %v", check.File, scriptError)
+			return "", errors.New("Script failed with error: " + scriptError)
+		}
+
+		// Execution failed
+		log.Debugf("Script %s finished with execution error: %v", check.File, err)
+		return "", errors.New("Script failed with error: " + err.Error())
+	}
+
+	// Check run successfull
+	return scriptResult, nil
+}
+
+// Converts the return value from the script check.
+// Format: value|label1:value1,label2:value2
+func convertResult(result string) (float64, map[string]string) {
+	var metricValue float64
+	var labels = make(map[string]string)
+
+	if strings.Contains(result, "|") {
+		splitResult := strings.Split(result, "|")
+
+		// Result of the check
+		value := splitResult[0]
+
+		// Labels of the check
+		splitLabels := strings.Split(splitResult[1], ",")
+		log.Tracef("--> split labels: %v", splitLabels)
+		for _, label := range splitLabels {
+			if(!strings.Contains(label, "=")) {
+				log.Warnf("Skipping label %s because wrong format detected in result: %s", label, result)
+			} else {
+				splitLabel := strings.SplitN(label, "=", 2)
+				labels[splitLabel[0]] = splitLabel[1]
+			}
+		}
+		metricValue, _ = strconv.ParseFloat(value, 64)
+	} else {
+		metricValue, _ = strconv.ParseFloat(result, 64)
+	}
+	return metricValue, labels
+}
+
+// Decodes the label values using the encoding of the check.
+// Values that cannot be decoded are kept as they are.
+func decodeLabels(labels map[string]string, encoding string) map[string]string {
+	switch encoding {
+	case "":
+		return labels
+	case "base64":
+		for key, value := range labels {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				log.Warnf("Failed to decode base64 value of label %s: %v", key, err)
+				continue
+			}
+			// Prometheus only accepts valid UTF-8 label values
+			labels[key] = strings.ToValidUTF8(string(decoded), "\uFFFD")
+		}
+	default:
+		log.Warnf("Not able to decode labels with unknown encoding %s", encoding)
+	}
+	return labels
+}
+
+// Convert the keys from a map to a slice.
+func convertMapKeysToSlice(value map[string]string) []string {
+	keys := make([]string, len(value))
+
+	i := 0
+	for k := range value {
+		keys[i] = k
+		i++
+	}
+
+	return keys
+}
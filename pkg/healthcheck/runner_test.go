@@ -0,0 +1,328 @@
+package healthcheck
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type testpairResult struct {
+	input  string
+	value  float64
+	labels map[string]string
+}
+
+var testsResult = []testpairResult{
+	{"1|label1=value1", 1, map[string]string{"label1": "value1"}},
+	{"1|label1=value1,label2=value2", 1, map[string]string{"label1": "value1", "label2": "value2"}},
+	{"1|label1=value1,label2=value2,label3=value3", 1, map[string]string{"label1": "value1", "label2": "value2", "label3": "value3"}},
+	{"1", 1, make(map[string]string)},
+	{"1|user=system:admin", 1, map[string]string{"user": "system:admin"}},
+	{"1|url=https://test.exapmle.com", 1, map[string]string{"url": "https://test.exapmle.com"}},
+	{"1|label1={field2: value2; field3: value3}", 1, map[string]string{"label1": "{field2: value2; field3: value3}"}},
+	// if you use ',' in a label value some text will be removed because ',' is used as separator
+	{"1|label1=i like apples, bananas and strawberries", 1, map[string]string{"label1": "i like apples"}},
+}
+
+func TestConvertResult(t *testing.T) {
+	for _, pair := range testsResult {
+		value, labels := convertResult(pair.input)
+
+		if value != pair.value {
+			t.Errorf("Expected metric value %f but found %f", pair.value, value)
+		}
+		if len(labels) != len(pair.labels) {
+			t.Errorf("Expected %d labels but found %d", len(pair.labels), len(labels))
+		}
+		eq := reflect.DeepEqual(labels, pair.labels)
+		if !eq {
+			t.Errorf("Expected labels %s but found %s", labels, pair.labels)
+		}
+	}
+}
+
+type testpairDecode struct {
+	encoding string
+	labels   map[string]string
+	decoded  map[string]string
+}
+
+var testsDecode = []testpairDecode{
+	{"", map[string]string{"label1": "value1"}, map[string]string{"label1": "value1"}},
+	{"base64", map[string]string{"label1": "dmFsdWUx"}, map[string]string{"label1": "value1"}},
+	// delimiters and newlines can be used in encoded values
+	{"base64", map[string]string{"label1": "YXBwbGVzLCBiYW5hbmFzfGxpbmUxCmxpbmUy"}, map[string]string{"label1": "apples, bananas|line1\nline2"}},
+	// invalid values are not decoded
+	{"base64", map[string]string{"label1": "not base64!"}, map[string]string{"label1": "not base64!"}},
+	{"unknown", map[string]string{"label1": "dmFsdWUx"}, map[string]string{"label1": "dmFsdWUx"}},
+}
+
+func TestDecodeLabels(t *testing.T) {
+	for _, pair := range testsDecode {
+		labels := decodeLabels(pair.labels, pair.encoding)
+
+		if !reflect.DeepEqual(labels, pair.decoded) {
+			t.Errorf("Expected labels %s but found %s", pair.decoded, labels)
+		}
+	}
+}
+
+type testpairMessage struct {
+	message   string
+	sanitized string
+}
+
+var testsMessage = []testpairMessage{
+	{"all fine", "all fine"},
+	{"  line1\nline2\ttab\x1b[0m ", "line1 line2 tab [0m"},
+	{strings.Repeat("x", 200), strings.Repeat("x", 117) + "..."},
+}
+
+func TestSanitizeMessage(t *testing.T) {
+	for _, pair := range testsMessage {
+		message := sanitizeMessage(pair.message)
+
+		if message != pair.sanitized {
+			t.Errorf("Expected message %s but found %s", pair.sanitized, message)
+		}
+	}
+}
+
+func TestShouldLogFailure(t *testing.T) {
+
+	logged := []int{}
+	for failures := 1; failures <= 25; failures++ {
+		if shouldLogFailure(failures, 10) {
+			logged = append(logged, failures)
+		}
+	}
+	if !reflect.DeepEqual(logged, []int{1, 10, 20}) {
+		t.Errorf("Expected failures 1, 10 and 20 to be logged but found %v", logged)
+	}
+
+	if shouldLogFailure(2, 0) {
+		t.Error("Expected only the first failure to be logged")
+	}
+}
+
+type testpairConstLabels struct {
+	owner   string
+	team    string
+	runbook string
+	labels  prometheus.Labels
+}
+
+var testsConstLabels = []testpairConstLabels{
+	{"", "", "", prometheus.Labels{}},
+	{"jane.doe@example.com", "", "", prometheus.Labels{"owner": "jane.doe@example.com"}},
+	{"", "platform", "https://runbooks.example.com", prometheus.Labels{"team": "platform", "runbook_url": "https://runbooks.example.com"}},
+	{"jane.doe@example.com", "platform", "https://runbooks.example.com", prometheus.Labels{"owner": "jane.doe@example.com", "team": "platform", "runbook_url": "https://runbooks.example.com"}},
+}
+
+func TestConstLabelsForCheck(t *testing.T) {
+	for _, pair := range testsConstLabels {
+		check := getPlaceholderCheck("test_const_labels", "Gauge")
+		check.Owner = pair.owner
+		check.Team = pair.team
+		check.RunbookURL = pair.runbook
+
+		labels := constLabelsForCheck(check)
+		if !reflect.DeepEqual(labels, pair.labels) {
+			t.Errorf("Expected labels %v but found %v", pair.labels, labels)
+		}
+	}
+}
+
+func TestStatusMetricsOwnership(t *testing.T) {
+
+	registry := prometheus.NewRegistry()
+	scheduler := NewScheduler(Options{Registerer: registry})
+	check := getPlaceholderCheck("test_ownership", "Gauge")
+	check.Active = false
+	check.Team = "platform"
+	check.RunbookURL = "https://runbooks.example.com"
+	scheduler.AddCheck(check)
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	// A failing check has no metric vectors, the ownership is part of the status metrics
+	scheduler.processResult(check, "", errors.New("failed"))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, family := range families {
+		if family.GetName() != "checkbot_lastresult_info" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			found = labels["team"] == "platform" && labels["runbook_url"] == "https://runbooks.example.com" && metric.GetGauge().GetValue() == 0
+		}
+	}
+	if !found {
+		t.Error("Expected failed lastresult metric with team and runbook")
+	}
+}
+
+type testpairFile struct {
+	path     string
+	filename string
+	result   string
+	hasError bool
+}
+
+var testFile = []testpairFile{
+	{"../../test/scripts/counter_result.sh", "counter_result", "1|label1=value1,label2=value2\n", false},
+	{"../../test/scripts/gauge_result.sh", "gauge_result", "42|label1=value1,label2=value2\n", false},
+	{"../../test/scripts/single_result.sh", "single_result", "42|label1=value1,label2=value2\n", false},
+	{"../../test/scripts/failed_result.sh", "failed_result", "", true},
+	{"../../test/scripts/empty_result.sh", "empty_result", "\n", false},
+}
+
+func TestRunScript(t *testing.T) {
+
+	for _, pair := range testFile {
+
+		file := pair.path
+		check := new(Check)
+		check = &Check{
+			Name:        pair.filename,
+			File:        file,
+			Interval:    10,
+			Active:      true,
+			MetricType:  extractMetadataFromFile(metaType, file),
+			Help:        extractMetadataFromFile(metaHelp, file),
+			stoppedchan: make(chan struct{}),
+			Nextrun:     time.Now().Unix(),
+		}
+
+		result, err := runBashScript(*check)
+
+		if err != nil {
+			if !pair.hasError {
+				t.Error("Error happened: ", err)
+			}
+		}
+		if result != pair.result {
+			t.Errorf("Expected result is %s but got %s", pair.result, result)
+		}
+	}
+}
+
+func TestRegisterMetricsSuccess(t *testing.T) {
+
+	check := getPlaceholderCheck("test_valid", "Gauge")
+
+	// Register a valid metric
+	registerMetricsForCheck(check, 42, map[string]string{"label1": "value1"})
+}
+
+func TestRegisterMetricsFailed(t *testing.T) {
+
+	check := getPlaceholderCheck("test_invalid", "Gauge")
+
+	// You can only register metrics with the same labels, this is not valid!
+	registerMetricsForCheck(check, 42, map[string]string{"label1": "value1"})
+	registerMetricsForCheck(check, 43, map[string]string{"label2": "value2"})
+}
+
+func TestRegisterMetricsRaw(t *testing.T) {
+
+	check := getPlaceholderCheck("test_raw", "Gauge")
+	check.Raw = true
+
+	rawRegistry := prometheus.NewRegistry()
+	NewScheduler(Options{RawRegisterer: rawRegistry}).AddCheck(check)
+
+	// Raw metrics are only registered in the raw registry
+	registerMetricsForCheck(check, 42, map[string]string{"label1": "value1"})
+
+	if countMetrics(t, rawRegistry, "test_raw") != 1 {
+		t.Error("Expected metric in raw registry")
+	}
+	if countMetrics(t, prometheus.DefaultGatherer, "test_raw") != 0 {
+		t.Error("Expected no metric in default registry")
+	}
+
+	unregisterMetricsForCheck(check)
+}
+
+// Count the metrics with a given name in a registry.
+func countMetrics(t *testing.T, gatherer prometheus.Gatherer, name string) int {
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return len(family.GetMetric())
+		}
+	}
+	return 0
+}
+
+func getPlaceholderCheck(metricName string, metricType string) *Check {
+
+	check := new(Check)
+	check = &Check{
+		Name:        metricName,
+		File:        "placeholder",
+		Interval:    10,
+		Active:      true,
+		MetricType:  metricType,
+		Help:        "placeholder",
+		stoppedchan: make(chan struct{}),
+		Nextrun:     time.Now().Unix(),
+	}
+
+	return check
+}
+
+var testCheck = []Check{
+	{
+		Name:          "test_check_a",
+		File:          "check_a.sh",
+		Interval:      60,
+		Active:        true,
+		MetricType:    "Gauge",
+		Help:          "this is a test check a",
+		metric:        prometheus.NewGaugeVec(prometheus.GaugeOpts{}, []string{}),
+		resultLast:    []map[string]string{{"label1": "value1", "label2": "value2"}, {"label1": "value3", "label2": "value4"}},
+		resultCurrent: []map[string]string{{"label1": "value1", "label2": "value2"}},
+		stoppedchan:   nil,
+		Offset:        30,
+		Nextrun:       0,
+		Success:       -1,
+	},
+}
+
+func TestCleanupUnusedDimensions(t *testing.T) {
+
+	for _, pair := range testCheck {
+
+		resultLast := pair.resultLast
+		resultCurrent := pair.resultCurrent
+
+		t.Log(resultLast)
+
+		cleanupUnusedDimensions(&pair)
+
+		if len(pair.resultLast) != len(resultLast) {
+			t.Error("Length of resultLast should be equal")
+		}
+		if len(pair.resultCurrent) != len(resultCurrent) {
+			t.Error("Length of resultCurrent should be equal")
+		}
+
+	}
+}
@@ -0,0 +1,275 @@
+package healthcheck
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Options configure a Scheduler.
+type Options struct {
+	ScriptBase      string                // Base path for the check scripts
+	MetricsPrefix   string                // Prefix for all metrics, checkbot if empty
+	StatusPrefix    string                // Prefix for the status metrics, MetricsPrefix if empty
+	Proxy           ProxyConfig           // Proxy used by the check scripts
+	LogFailureEvery int                   // Log the first and every nth failure in a row of a check
+	LoadShedder     *LoadShedder          // Limits concurrent checks, unlimited if nil
+	OnResult        func(check *Check)    // Called after every run of a check
+	Registerer      prometheus.Registerer // Registry for all metrics, the default registry if nil
+	RawRegisterer   prometheus.Registerer // Registry for checks with # RAW true, Registerer if nil
+}
+
+// Scheduler runs the checks regularly and exports their results as metrics.
+type Scheduler struct {
+	options          Options
+	checkList        map[string]*Check
+	added            map[string]*Check // Checks added with AddCheck, kept on load
+	lastrunMetric    *prometheus.GaugeVec
+	lastresultMetric *prometheus.GaugeVec
+	failuresMetric   *prometheus.GaugeVec
+	silencedMetric   *prometheus.GaugeVec
+	registered       []prometheus.Collector // Status metrics to unregister on stop
+	stopchan         chan struct{}          // A channel to tell it to stop
+	mutex            sync.RWMutex           // Protects the checks while running
+}
+
+// NewScheduler creates a new scheduler without any checks.
+func NewScheduler(options Options) *Scheduler {
+	if options.MetricsPrefix == "" {
+		options.MetricsPrefix = "checkbot"
+	}
+	if options.StatusPrefix == "" {
+		options.StatusPrefix = options.MetricsPrefix
+	}
+	if options.Registerer == nil {
+		options.Registerer = prometheus.DefaultRegisterer
+	}
+	if options.RawRegisterer == nil {
+		options.RawRegisterer = options.Registerer
+	}
+
+	return &Scheduler{
+		options:   options,
+		checkList: map[string]*Check{},
+		added:     map[string]*Check{},
+	}
+}
+
+// Load reads all the scripts from the script base and replaces the checks.
+// Checks added with AddCheck are kept and take precedence over scripts with the same name.
+// Scripts with invalid metadata are skipped and returned as error.
+// The scheduler must not be running.
+func (s *Scheduler) Load() error {
+	checkList, err := LoadChecks(s.options.ScriptBase, s.options.MetricsPrefix, s.options.Proxy)
+	for _, check := range checkList {
+		s.prepareCheck(check)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name, check := range s.added {
+		if _, ok := checkList[name]; ok {
+			log.Warnf("Check %s is added programmatically, ignoring script %s", name, checkList[name].File)
+		}
+		checkList[name] = check
+	}
+	s.checkList = checkList
+	return err
+}
+
+// AddCheck adds a check to the scheduler, the check is kept on reload.
+// If the scheduler is running the check is started immediately.
+func (s *Scheduler) AddCheck(check *Check) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.checkList[check.Name]; ok {
+		return errors.New("Check " + check.Name + " already exists")
+	}
+
+	s.prepareCheck(check)
+	s.added[check.Name] = check
+	s.checkList[check.Name] = check
+
+	if s.stopchan != nil {
+		s.startCheck(check)
+	}
+	return nil
+}
+
+// Prepare a check to be run by the scheduler.
+func (s *Scheduler) prepareCheck(check *Check) {
+	check.registerer = s.options.Registerer
+	if check.Raw {
+		check.registerer = s.options.RawRegisterer
+	}
+}
+
+// Checks returns a snapshot of all checks of the scheduler.
+// The checks are copies, changing them does not affect the scheduler.
+func (s *Scheduler) Checks() map[string]*Check {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	checks := make(map[string]*Check, len(s.checkList))
+	for name, check := range s.checkList {
+		snapshot := *check
+		checks[name] = &snapshot
+	}
+	return checks
+}
+
+// Reload stops all checks, reads the scripts again and restarts the checks.
+// Scripts with invalid metadata are skipped and returned as error.
+func (s *Scheduler) Reload() error {
+	s.Stop()
+	err := s.Load()
+	s.Start()
+	return err
+}
+
+// Start starts a go routine for each active check.
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastrunMetric = s.registerStatusMetric("lastrun", "Provides information about the last run of a script.")
+	s.lastresultMetric = s.registerStatusMetric("lastresult", "Provides information about the last result of a script.")
+	s.failuresMetric = s.registerStatusMetric("consecutive_failures", "Provides information about the number of consecutive failures of a script.")
+	s.silencedMetric = s.registerStatusMetric("silenced", "Provides information if a script is silenced.")
+
+	log.Debug("Starting all checks now..")
+
+	// Recreate the chan in case it was closed before
+	s.stopchan = make(chan struct{})
+
+	// Walk throught the check list
+	for _, check := range s.checkList {
+		s.startCheck(check)
+	}
+}
+
+// Start a go routine for a check if it is active, the mutex must be held.
+func (s *Scheduler) startCheck(check *Check) {
+	// Only run the check if active
+	if !check.Active {
+		log.Infof("Check %s not active", check.Name)
+		return
+	}
+
+	// A new chan for every run, the previous one was closed on stop
+	check.stoppedchan = make(chan struct{})
+	go s.runCheck(check, s.stopchan)
+}
+
+// Stop stops all running go routines and waits until they are finished.
+func (s *Scheduler) Stop() {
+
+	s.mutex.Lock()
+
+	// Nothing to stop if the scheduler was not started
+	if s.stopchan == nil {
+		s.mutex.Unlock()
+		return
+	}
+
+	log.Debug("Stopping all checks now..")
+	close(s.stopchan)
+	s.stopchan = nil
+
+	// Walk throught the check list
+	stoppedchans := []chan struct{}{}
+	for _, check := range s.checkList {
+		if check.Active {
+			stoppedchans = append(stoppedchans, check.stoppedchan)
+		}
+	}
+
+	// The checks need the mutex to finish
+	s.mutex.Unlock()
+	for _, stoppedchan := range stoppedchans {
+		<-stoppedchan
+	}
+
+	// Reset the status metrics registered by this scheduler
+	s.mutex.Lock()
+	for _, metric := range s.registered {
+		s.options.Registerer.Unregister(metric)
+	}
+	s.registered = nil
+	s.mutex.Unlock()
+	log.Debug("Unregistered status metrics")
+
+	log.Debug("All checks are stopped.")
+}
+
+// Trigger triggers an immediate run of a check, a silenced check will run again.
+func (s *Scheduler) Trigger(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	check, ok := s.checkList[name]
+	if !ok {
+		return errors.New("Unknown check " + name)
+	}
+	if !check.Active {
+		return errors.New("Check " + name + " is not active")
+	}
+
+	check.Silenced = 0
+	check.Nextrun = time.Now().Unix()
+	if s.silencedMetric != nil {
+		s.silencedMetric.With(statusLabels(check)).Set(0)
+	}
+	log.Infof("Triggered run of check %s", check.Name)
+	return nil
+}
+
+// Silence silences a check for the given duration, the check will not run until then.
+func (s *Scheduler) Silence(name string, duration time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	check, ok := s.checkList[name]
+	if !ok {
+		return errors.New("Unknown check " + name)
+	}
+
+	check.Silenced = time.Now().Add(duration).Unix()
+	if s.silencedMetric != nil {
+		s.silencedMetric.With(statusLabels(check)).Set(1)
+	}
+	log.Infof("Silenced check %s until %s", check.Name, time.Unix(check.Silenced, 0))
+	return nil
+}
+
+// Setup a status metric with information about all checks, e.g. lastrun.
+// An already registered metric is reused but not unregistered on stop.
+func (s *Scheduler) registerStatusMetric(name string, help string) *prometheus.GaugeVec {
+	metric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: s.options.StatusPrefix + "_" + name + "_info",
+			Help: help,
+		},
+		[]string{"name", "interval", "offset", "type", "owner", "team", "runbook_url"},
+	)
+
+	err := s.options.Registerer.Register(metric)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if existing, ok := are.ExistingCollector.(*prometheus.GaugeVec); ok {
+				return existing
+			}
+		}
+		log.Warnf("Failed to register metric %s: %v", name, err)
+		return metric
+	}
+
+	s.registered = append(s.registered, metric)
+	log.Debugf("Registering metric %s", name)
+	return metric
+}
@@ -0,0 +1,337 @@
+package healthcheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLoadChecks(t *testing.T) {
+
+	// Paths containing .. are skipped, so the script base must be absolute
+	scriptBase, _ := filepath.Abs("../../test/scripts")
+	checks, err := LoadChecks(scriptBase, "test", ProxyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Golden files are not loaded as checks
+	if len(checks) != 5 {
+		t.Errorf("Expected 5 checks but found %d", len(checks))
+	}
+
+	check, ok := checks["test_gauge_result"]
+	if !ok {
+		t.Fatal("Expected check test_gauge_result")
+	}
+	if check.MetricType != "Gauge" || check.Interval != 10 || !check.Active {
+		t.Errorf("Unexpected check %s", check.String())
+	}
+
+	// The content of each script is hashed
+	if len(check.Hash) != 64 || check.Hash == checks["test_counter_result"].Hash {
+		t.Errorf("Unexpected hash %s", check.Hash)
+	}
+}
+
+func TestTriggerAndSilence(t *testing.T) {
+
+	scheduler := NewScheduler(Options{})
+	check, err := NewCheck("test_scheduler", "placeholder", "Gauge", "placeholder", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.AddCheck(check)
+
+	err = scheduler.Silence(check.Name, 5*time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	if check.Silenced <= time.Now().Unix() {
+		t.Error("Expected check to be silenced")
+	}
+
+	err = scheduler.Trigger(check.Name)
+	if err != nil {
+		t.Error(err)
+	}
+	if check.Silenced != 0 || check.Nextrun > time.Now().Unix() {
+		t.Error("Expected check to run now")
+	}
+
+	if scheduler.Trigger("unknown") == nil {
+		t.Error("Expected error for unknown check")
+	}
+}
+
+func TestTriggerWhileRunning(t *testing.T) {
+
+	registry := prometheus.NewRegistry()
+	scheduler := NewScheduler(Options{Registerer: registry})
+	check, err := NewCheck("test_running", "../../test/scripts/gauge_result.sh", "Gauge", "placeholder", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check.Nextrun = 0
+	scheduler.AddCheck(check)
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	// The check runs immediately
+	for i := 0; i < 50 && scheduler.Checks()[check.Name].Success == -1; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if scheduler.Checks()[check.Name].Success != 1 {
+		t.Fatal("Expected check to run successfully")
+	}
+
+	// Trigger and silence the check while the runner is using it
+	for i := 0; i < 30; i++ {
+		scheduler.Trigger(check.Name)
+		if i%10 == 9 {
+			scheduler.Silence(check.Name, time.Minute)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	scheduler.Silence(check.Name, time.Minute)
+	if scheduler.Checks()[check.Name].Silenced <= time.Now().Unix() {
+		t.Error("Expected check to be silenced")
+	}
+	if gaugeValue(t, registry, "checkbot_silenced_info") != 1 {
+		t.Error("Expected silenced metric to be 1")
+	}
+
+	scheduler.Trigger(check.Name)
+	if scheduler.Checks()[check.Name].Silenced != 0 {
+		t.Error("Expected check not to be silenced after trigger")
+	}
+	if gaugeValue(t, registry, "checkbot_silenced_info") != 0 {
+		t.Error("Expected silenced metric to be 0")
+	}
+}
+
+func TestLoadChecksInvalidInterval(t *testing.T) {
+
+	scriptBase := t.TempDir()
+	testpairs := map[string]string{
+		"interval_one.sh":     "# ACTIVE true\n# TYPE Gauge\n# HELP placeholder\n# INTERVAL 1\n",
+		"interval_zero.sh":    "# ACTIVE true\n# TYPE Gauge\n# HELP placeholder\n# INTERVAL 0\n",
+		"interval_missing.sh": "# ACTIVE true\n# TYPE Gauge\n# HELP placeholder\n",
+	}
+	for name, script := range testpairs {
+		err := os.WriteFile(filepath.Join(scriptBase, name), []byte(script), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checks, err := LoadChecks(scriptBase, "test", ProxyConfig{})
+	if err == nil {
+		t.Error("Expected error for invalid intervals")
+	}
+	if len(checks) != 1 {
+		t.Fatalf("Expected 1 check but found %d", len(checks))
+	}
+	if check, ok := checks["test_interval_one"]; !ok || check.Offset != 0 {
+		t.Error("Expected check test_interval_one without offset")
+	}
+}
+
+func TestLoadChecksGoldenImpliesDrift(t *testing.T) {
+
+	scriptBase := t.TempDir()
+	script := "# ACTIVE true\n# TYPE Gauge\n# HELP placeholder\n# INTERVAL 10\n# GOLDEN golden_result.golden\n"
+	err := os.WriteFile(filepath.Join(scriptBase, "golden_result.sh"), []byte(script), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := LoadChecks(scriptBase, "test", ProxyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check, ok := checks["test_golden_result"]; !ok || !check.Drift {
+		t.Error("Expected check test_golden_result with drift detection")
+	}
+}
+
+func TestStatusMetricsRegistry(t *testing.T) {
+
+	registry := prometheus.NewRegistry()
+	scheduler := NewScheduler(Options{MetricsPrefix: "mytool", Registerer: registry})
+	check := getPlaceholderCheck("mytool_registry", "Gauge")
+	check.Active = false
+	scheduler.AddCheck(check)
+
+	// Status metrics are named after the prefix and registered in the given registry
+	scheduler.Start()
+	scheduler.Silence(check.Name, time.Minute)
+	if countMetrics(t, registry, "mytool_silenced_info") != 1 {
+		t.Error("Expected silenced metric in registry")
+	}
+	if countMetrics(t, prometheus.DefaultGatherer, "mytool_silenced_info") != 0 {
+		t.Error("Expected no silenced metric in default registry")
+	}
+
+	scheduler.Stop()
+	if countMetrics(t, registry, "mytool_silenced_info") != 0 {
+		t.Error("Expected silenced metric to be unregistered")
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+
+	registry := prometheus.NewRegistry()
+	scheduler := NewScheduler(Options{Registerer: registry})
+	check := getPlaceholderCheck("test_stop", "Gauge")
+	check.Active = false
+	scheduler.AddCheck(check)
+
+	// Stopping a scheduler which is not running does nothing
+	scheduler.Stop()
+	if scheduler.stopchan != nil {
+		t.Error("Expected scheduler not to be running")
+	}
+
+	scheduler.Start()
+	scheduler.Silence(check.Name, time.Minute)
+	if countMetrics(t, registry, "checkbot_silenced_info") != 1 {
+		t.Error("Expected silenced metric while running")
+	}
+
+	// Stopping twice unregisters the status metrics once
+	scheduler.Stop()
+	scheduler.Stop()
+	if scheduler.stopchan != nil {
+		t.Error("Expected scheduler to be stopped")
+	}
+	if countMetrics(t, registry, "checkbot_silenced_info") != 0 {
+		t.Error("Expected silenced metric to be unregistered")
+	}
+}
+
+func TestChecksSnapshot(t *testing.T) {
+
+	scheduler := NewScheduler(Options{})
+	check, err := NewCheck("test_snapshot", "placeholder", "Gauge", "placeholder", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.AddCheck(check)
+
+	// Changing the snapshot does not change the check of the scheduler
+	snapshot := scheduler.Checks()[check.Name]
+	snapshot.Silenced = 42
+	if check.Silenced == 42 {
+		t.Error("Expected snapshot to be a copy of the check")
+	}
+}
+
+func TestRestart(t *testing.T) {
+
+	scheduler := NewScheduler(Options{Registerer: prometheus.NewRegistry()})
+	check, err := NewCheck("test_restart", "../../test/scripts/gauge_result.sh", "Gauge", "placeholder", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.AddCheck(check)
+
+	// A stopped scheduler can be started and stopped again
+	done := make(chan struct{})
+	go func() {
+		scheduler.Start()
+		scheduler.Stop()
+		scheduler.Start()
+		scheduler.Stop()
+
+		// Give a runner which was not waited for the time to close its chan
+		time.Sleep(2 * time.Second)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected scheduler to stop in time")
+	}
+}
+
+func TestAddCheckAndReload(t *testing.T) {
+
+	// Paths containing .. are skipped, so the script base must be absolute
+	scriptBase, _ := filepath.Abs("../../test/scripts")
+	scheduler := NewScheduler(Options{ScriptBase: scriptBase, MetricsPrefix: "test", Registerer: prometheus.NewRegistry()})
+	scheduler.Load()
+	scheduler.Start()
+
+	// A check added while running is started and stopped with the others
+	check, err := NewCheck("test_added", "../../test/scripts/gauge_result.sh", "Gauge", "placeholder", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = scheduler.AddCheck(check)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheduler.AddCheck(check) == nil {
+		t.Error("Expected error for duplicate check")
+	}
+
+	// Added checks are kept on reload
+	done := make(chan struct{})
+	go func() {
+		scheduler.Reload()
+		scheduler.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected scheduler to stop in time")
+	}
+
+	checks := scheduler.Checks()
+	if _, ok := checks["test_added"]; !ok {
+		t.Error("Expected added check to be kept on reload")
+	}
+	if _, ok := checks["test_gauge_result"]; !ok {
+		t.Error("Expected checks from scripts after reload")
+	}
+}
+
+func TestStatusPrefix(t *testing.T) {
+
+	registry := prometheus.NewRegistry()
+	scheduler := NewScheduler(Options{MetricsPrefix: "mytool", StatusPrefix: "checkbot", Registerer: registry})
+	check := getPlaceholderCheck("mytool_status", "Gauge")
+	check.Active = false
+	scheduler.AddCheck(check)
+
+	// Status metrics use the status prefix instead of the metrics prefix
+	scheduler.Start()
+	scheduler.Silence(check.Name, time.Minute)
+	if countMetrics(t, registry, "checkbot_silenced_info") != 1 {
+		t.Error("Expected silenced metric with status prefix")
+	}
+	scheduler.Stop()
+}
+
+// Return the value of the first gauge with the given name.
+func gaugeValue(t *testing.T, gatherer prometheus.Gatherer, name string) float64 {
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, family := range families {
+		if family.GetName() == name && len(family.GetMetric()) > 0 {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Errorf("Expected gauge %s", name)
+	return -1
+}